@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScheduledWorkout is a plan the user scheduled on a date, it is done when a
+// workout of the plan was started on that day.
+type ScheduledWorkout struct {
+	ID        uint
+	CreatedAt time.Time
+	Username  string    `gorm:"index"`
+	Date      time.Time `gorm:"type:date;index"`
+	PlanID    uint
+	Plan      Plan `gorm:"constraint:OnDelete:CASCADE"`
+	Done      bool `gorm:"-"`
+}
+
+// ScheduleInput is a plan to schedule on a date as entered.
+type ScheduleInput struct {
+	Date   string `form:"date" binding:"required"`
+	PlanID uint   `form:"plan" binding:"required"`
+}
+
+// CalendarFeed is the secret token of the iCalendar feed of a user, the feed
+// is fetched by calendar apps which cannot authenticate.
+type CalendarFeed struct {
+	Username  string `gorm:"primaryKey"`
+	UpdatedAt time.Time
+	Token     string `gorm:"uniqueIndex"`
+}
+
+// CalendarFilter selects the month or week shown around the date.
+type CalendarFilter struct {
+	View string `form:"view,default=month" binding:"oneof=month week"`
+	Date string `form:"date"`
+}
+
+// CalendarDay is a day of the calendar with its scheduled and performed
+// workouts.
+type CalendarDay struct {
+	Date      time.Time
+	Outside   bool
+	Today     bool
+	Scheduled []ScheduledWorkout
+	Workouts  []Workout
+}
+
+// feedDays is how far ahead the iCalendar feed lists scheduled workouts.
+const feedDays = 90
+
+// calendarRange returns the first day shown and the day after the last,
+// months are shown in full weeks.
+func (f CalendarFilter) calendarRange(day time.Time) (time.Time, time.Time) {
+	if f.View == "week" {
+		start := weekStart(day)
+		return start, start.AddDate(0, 0, 7)
+	}
+	first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	end := weekStart(first.AddDate(0, 1, -1)).AddDate(0, 0, 7)
+	return weekStart(first), end
+}
+
+// calendarWeeks places the workouts on the days from start to end, days not
+// in the month are marked outside unless it is 0. A scheduled workout is done
+// with a workout of its plan on the same day.
+func calendarWeeks(start, end, today time.Time, month time.Month, scheduled []ScheduledWorkout, workouts []Workout) [][]CalendarDay {
+	weeks := [][]CalendarDay{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if weekday(day) == 0 {
+			weeks = append(weeks, []CalendarDay{})
+		}
+		calendarDay := CalendarDay{
+			Date:    day,
+			Outside: month != 0 && day.Month() != month,
+			Today:   day.Equal(today),
+		}
+		next := day.AddDate(0, 0, 1)
+		for _, workout := range workouts {
+			if !workout.CreatedAt.Before(day) && workout.CreatedAt.Before(next) {
+				calendarDay.Workouts = append(calendarDay.Workouts, workout)
+			}
+		}
+		for _, entry := range scheduled {
+			if !sameDay(entry.Date, day) {
+				continue
+			}
+			for _, workout := range calendarDay.Workouts {
+				if workout.PlanID != nil && *workout.PlanID == entry.PlanID {
+					entry.Done = true
+				}
+			}
+			calendarDay.Scheduled = append(calendarDay.Scheduled, entry)
+		}
+		weeks[len(weeks)-1] = append(weeks[len(weeks)-1], calendarDay)
+	}
+	return weeks
+}
+
+// sameDay compares the dates ignoring the time and location, dates are read
+// from the database in UTC.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Calendar shows the scheduled and performed workouts of a month or week.
+func (a *App) Calendar(c *gin.Context) {
+	a.renderCalendar(c, nil)
+}
+
+// ScheduleWorkout schedules the plan on the date.
+func (a *App) ScheduleWorkout(c *gin.Context) {
+	var input ScheduleInput
+	var date time.Time
+	err := c.ShouldBindWith(&input, binding.Form)
+	if err == nil {
+		date, err = time.ParseInLocation(time.DateOnly, input.Date, time.Local)
+	}
+	if err == nil {
+		err = gorm.G[ScheduledWorkout](a.db).Create(*a.ctx, &ScheduledWorkout{
+			Username: currentUser(c),
+			Date:     date,
+			PlanID:   input.PlanID,
+		})
+	}
+	if err != nil {
+		log.Printf("schedule error: %v", err)
+	}
+	a.renderCalendar(c, err)
+}
+
+// UnscheduleWorkout removes a scheduled workout of the user.
+func (a *App) UnscheduleWorkout(c *gin.Context) {
+	_, err := gorm.G[ScheduledWorkout](a.db).
+		Where("id = ? AND username = ?", c.Param("id"), currentUser(c)).
+		Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderCalendar(c, err)
+}
+
+// RenewCalendarFeed creates a new token for the feed of the user, the link
+// with the old one stops working.
+func (a *App) RenewCalendarFeed(c *gin.Context) {
+	token, err := randomToken()
+	if err == nil {
+		err = gorm.G[CalendarFeed](a.db, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "token"}),
+		}).Create(*a.ctx, &CalendarFeed{Username: currentUser(c), Token: token})
+	}
+	if err != nil {
+		log.Printf("feed error: %v", err)
+	}
+	a.renderCalendar(c, err)
+}
+
+func (a *App) renderCalendar(c *gin.Context, err error) {
+	var filter CalendarFilter
+	today := weekStart(a.now()).AddDate(0, 0, weekday(a.now()))
+	day := today
+	inputErr := c.ShouldBindWith(&filter, binding.Query)
+	if inputErr == nil && filter.Date != "" {
+		day, inputErr = time.ParseInLocation(time.DateOnly, filter.Date, time.Local)
+	}
+	if inputErr != nil {
+		log.Printf("calendar error: %v", inputErr)
+		err = errors.Join(err, inputErr)
+		filter, day = CalendarFilter{View: "month"}, today
+	}
+
+	start, end := filter.calendarRange(day)
+	scheduled, dbErr := gorm.G[ScheduledWorkout](a.db).
+		Preload("Plan", nil).
+		Where("username = ? AND date >= ? AND date < ?", currentUser(c), start, end).
+		Order("date, id").
+		Find(*a.ctx)
+	var workouts []Workout
+	if dbErr == nil {
+		workouts, dbErr = gorm.G[Workout](a.db).
+			Preload("Plan", nil).
+			Where("created_at >= ? AND created_at < ?", start, end).
+			Order("created_at").
+			Find(*a.ctx)
+	}
+	var plans []Plan
+	if dbErr == nil {
+		plans, dbErr = gorm.G[Plan](a.db).Order("name").Find(*a.ctx)
+	}
+	var feed CalendarFeed
+	if dbErr == nil {
+		feed, dbErr = gorm.G[CalendarFeed](a.db).Where("username = ?", currentUser(c)).First(*a.ctx)
+		if errors.Is(dbErr, gorm.ErrRecordNotFound) {
+			dbErr = nil
+		}
+	}
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+
+	previous, next := day.AddDate(0, -1, 0), day.AddDate(0, 1, 0)
+	title, month := day.Format("January 2006"), day.Month()
+	if filter.View == "week" {
+		previous, next = day.AddDate(0, 0, -7), day.AddDate(0, 0, 7)
+		title, month = "Week of "+start.Format(time.DateOnly), 0
+	}
+	data := map[string]any{
+		"Title":    title,
+		"View":     filter.View,
+		"Date":     day.Format(time.DateOnly),
+		"Previous": previous.Format(time.DateOnly),
+		"Next":     next.Format(time.DateOnly),
+		"Weekdays": weekdays,
+		"Weeks":    calendarWeeks(start, end, today, month, scheduled, workouts),
+		"Plans":    plans,
+	}
+	if feed.Token != "" {
+		data["Feed"] = absoluteURL(c, "/calendar/feed/"+feed.Token+".ics")
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/calendar.html").
+		SetData(data).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// CalendarFeedICS serves the scheduled workouts of the coming days as an
+// iCalendar feed, each event lists the exercises of the plan.
+func (a *App) CalendarFeedICS(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok || token == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	feed, err := gorm.G[CalendarFeed](a.db).Where("token = ?", token).First(*a.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	today := weekStart(a.now()).AddDate(0, 0, weekday(a.now()))
+	var scheduled []ScheduledWorkout
+	if err == nil {
+		scheduled, err = gorm.G[ScheduledWorkout](a.db).
+			Preload("Plan", nil).
+			Preload("Plan.Sets", func(db gorm.PreloadBuilder) error {
+				db.Order("position")
+				return nil
+			}).
+			Preload("Plan.Sets.Units", func(db gorm.PreloadBuilder) error {
+				db.Order("position")
+				return nil
+			}).
+			Preload("Plan.Sets.Units.Exercise", nil).
+			Where("username = ? AND date >= ? AND date < ?", feed.Username, today, today.AddDate(0, 0, feedDays)).
+			Order("date, id").
+			Find(*a.ctx)
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(icsCalendar(scheduled, a.now())))
+}
+
+// icsCalendar formats the scheduled workouts as all day events.
+func icsCalendar(scheduled []ScheduledWorkout, stamp time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//haering.dev//Workout Tracker//EN",
+		"X-WR-CALNAME:Workouts",
+	}
+	for _, entry := range scheduled {
+		exercises := []string{}
+		for _, set := range entry.Plan.Sets {
+			for _, unit := range set.Units {
+				exercise := unit.Exercise.Name
+				if unit.Reps > 0 {
+					exercise += fmt.Sprintf(" %d × %d", max(unit.Sets, 1), unit.Reps)
+				}
+				if unit.Weight > 0 {
+					exercise += " × " + formatNumber(unit.Weight)
+				}
+				exercises = append(exercises, exercise)
+			}
+		}
+		date := time.Date(entry.Date.Year(), entry.Date.Month(), entry.Date.Day(), 0, 0, 0, 0, time.UTC)
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:scheduled-"+strconv.FormatUint(uint64(entry.ID), 10)+"@workout-tracker",
+			"DTSTAMP:"+stamp.UTC().Format("20060102T150405Z"),
+			"DTSTART;VALUE=DATE:"+date.Format("20060102"),
+			"DTEND;VALUE=DATE:"+date.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+icsText(entry.Plan.Name),
+			"DESCRIPTION:"+icsText(strings.Join(exercises, "\n")),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for i, line := range lines {
+		lines[i] = icsFold(line)
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// icsText escapes a text value.
+func icsText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// icsFold folds a line into lines of at most 75 bytes, continued lines start
+// with a space. Multi byte characters are not split.
+func icsFold(line string) string {
+	var folded strings.Builder
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > 75 {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(r)
+		length += size
+	}
+	return folded.String()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	scheduledCols = []string{"ID", "CreatedAt", "Username", "Date", "PlanID"}
+	feedCols      = []string{"Username", "UpdatedAt", "Token"}
+	// the month of programNow is shown from the monday before the 1st to
+	// the sunday after the 31st
+	monthStart = time.Date(2025, 9, 29, 0, 0, 0, 0, time.Local)
+	monthEnd   = time.Date(2025, 11, 3, 0, 0, 0, 0, time.Local)
+)
+
+func TestICSCalendar(t *testing.T) {
+	assert.Equal(t, `Legs\; squat\, lunge\nrest \\ stretch`, icsText("Legs; squat, lunge\nrest \\ stretch"))
+
+	line := strings.Repeat("a", 74) + "ää"
+	assert.Equal(t, strings.Repeat("a", 74)+"\r\n ää", icsFold(line))
+	assert.Equal(t, "short", icsFold("short"))
+
+	date := time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC)
+	plan := Plan{Name: "Push day", Sets: []Set{{Units: []Unit{
+		{Exercise: Exercise{Name: "bench press"}, UnitTargets: UnitTargets{Sets: 5, Reps: 5, Weight: 80}},
+		{Exercise: Exercise{Name: "dips"}},
+	}}}}
+	assert.Equal(t, "BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"PRODID:-//haering.dev//Workout Tracker//EN\r\n"+
+		"X-WR-CALNAME:Workouts\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:scheduled-3@workout-tracker\r\n"+
+		"DTSTAMP:20251015T100000Z\r\n"+
+		"DTSTART;VALUE=DATE:20251017\r\n"+
+		"DTEND;VALUE=DATE:20251018\r\n"+
+		"SUMMARY:Push day\r\n"+
+		`DESCRIPTION:bench press 5 × 5 × 80\ndips`+"\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n",
+		icsCalendar([]ScheduledWorkout{{ID: 3, Date: date, Plan: plan}}, time.Date(2025, 10, 15, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestCalendarWeeks(t *testing.T) {
+	planID := uint(1)
+	today := time.Date(2025, 10, 15, 0, 0, 0, 0, time.Local)
+	scheduled := []ScheduledWorkout{
+		{ID: 1, Date: time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC), PlanID: 1},
+		{ID: 2, Date: time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC), PlanID: 1},
+	}
+	workouts := []Workout{{ID: 5, CreatedAt: time.Date(2025, 10, 13, 18, 0, 0, 0, time.Local), PlanID: &planID}}
+
+	weeks := calendarWeeks(monthStart, monthEnd, today, time.October, scheduled, workouts)
+	assert.Len(t, weeks, 5)
+	assert.True(t, weeks[0][0].Outside)
+	assert.False(t, weeks[0][2].Outside)
+	monday := weeks[2][0]
+	assert.Equal(t, 13, monday.Date.Day())
+	assert.Len(t, monday.Workouts, 1)
+	assert.True(t, monday.Scheduled[0].Done)
+	wednesday := weeks[2][2]
+	assert.True(t, wednesday.Today)
+	assert.False(t, wednesday.Scheduled[0].Done)
+
+	start, end := CalendarFilter{View: "week"}.calendarRange(today)
+	assert.Equal(t, time.Date(2025, 10, 13, 0, 0, 0, 0, time.Local), start)
+	assert.Equal(t, time.Date(2025, 10, 20, 0, 0, 0, 0, time.Local), end)
+	start, end = CalendarFilter{View: "month"}.calendarRange(today)
+	assert.Equal(t, monthStart, start)
+	assert.Equal(t, monthEnd, end)
+}
+
+func TestCalendar(t *testing.T) {
+	router, app := SetupTestApp()
+	app.mockNow = &programNow
+	weekStart := time.Date(2025, 10, 13, 0, 0, 0, 0, time.Local)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		fixture string
+		from    time.Time
+		to      time.Time
+		expect  func()
+	}{
+		{"GET", "/calendar", nil, "month.html", monthStart, monthEnd, func() {}},
+		{"GET", "/calendar?view=week&date=2025-10-17", nil, "week.html", weekStart, weekEnd, func() {}},
+		{"GET", "/calendar?view=year", nil, "invalid.html", monthStart, monthEnd, func() {}},
+		{"POST", "/calendar/schedule?view=week&date=2025-10-15", url.Values{"date": {"2025-10-17"}, "plan": {"1"}}, "schedule.html", weekStart, weekEnd, func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectQuery(`INSERT INTO "scheduled_workouts" ("created_at","username","date","plan_id") VALUES ($1,$2,$3,$4) RETURNING "id"`).
+				WithArgs(sqlmock.AnyArg(), "", time.Date(2025, 10, 17, 0, 0, 0, 0, time.Local), 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+			mocksql.ExpectCommit()
+		}},
+		{"POST", "/calendar/feed", nil, "feed.html", monthStart, monthEnd, func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`INSERT INTO "calendar_feeds" ("username","updated_at","token") VALUES ($1,$2,$3) ON CONFLICT ("username") DO UPDATE SET "updated_at"="excluded"."updated_at","token"="excluded"."token"`).
+				WithArgs("", sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+		{"DELETE", "/calendar/schedule/3", nil, "unschedule.html", monthStart, monthEnd, func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`DELETE FROM "scheduled_workouts" WHERE id = $1 AND username = $2`).
+				WithArgs("3", "").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			req.Host = "workout.example"
+			test.expect()
+			mocksql.ExpectQuery(`SELECT * FROM "scheduled_workouts" WHERE username = $1 AND date >= $2 AND date < $3 ORDER BY date, id`).
+				WithArgs("", test.from, test.to).
+				WillReturnRows(sqlmock.NewRows(scheduledCols).
+					AddRow(1, t1, "", time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC), 1).
+					AddRow(2, t1, "", time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC), 1))
+			mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+			mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at`).
+				WithArgs(test.from, test.to).
+				WillReturnRows(sqlmock.NewRows(workoutCols).
+					AddRow(5, time.Date(2025, 10, 13, 18, 0, 0, 0, time.Local), t1, 1, t1, 100.0).
+					AddRow(6, time.Date(2025, 10, 15, 8, 0, 0, 0, time.Local), t1, nil, nil, 100.0))
+			mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+			mocksql.ExpectQuery(`SELECT * FROM "plans" ORDER BY name`).
+				WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+			mocksql.ExpectQuery(`SELECT * FROM "calendar_feeds" WHERE username = $1 ORDER BY "calendar_feeds"."username" LIMIT $2`).
+				WithArgs("", 1).
+				WillReturnRows(sqlmock.NewRows(feedCols).AddRow("", t1, "0123abcd"))
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, filepath.Join("./fixtures/calendar", test.fixture), w)
+		})
+	}
+}
+
+func TestCalendarFeedICS(t *testing.T) {
+	router, app := SetupTestApp()
+	app.mockNow = &programNow
+	today := time.Date(2025, 10, 15, 0, 0, 0, 0, time.Local)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/calendar/feed/0123abcd.ics", nil)
+	mocksql.ExpectQuery(`SELECT * FROM "calendar_feeds" WHERE token = $1 ORDER BY "calendar_feeds"."username" LIMIT $2`).
+		WithArgs("0123abcd", 1).
+		WillReturnRows(sqlmock.NewRows(feedCols).AddRow("alice", t1, "0123abcd"))
+	mocksql.ExpectQuery(`SELECT * FROM "scheduled_workouts" WHERE username = $1 AND date >= $2 AND date < $3 ORDER BY date, id`).
+		WithArgs("alice", today, today.AddDate(0, 0, feedDays)).
+		WillReturnRows(sqlmock.NewRows(scheduledCols).
+			AddRow(2, t1, "alice", time.Date(2025, 10, 15, 0, 0, 0, 0, time.UTC), 1))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1 ORDER BY position`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 0, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."set_id" = $1 ORDER BY position`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	validateFixture(t, "./fixtures/calendar/feed.ics", w)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/calendar/feed/unknown.ics", nil)
+	mocksql.ExpectQuery(`SELECT * FROM "calendar_feeds" WHERE token = $1 ORDER BY "calendar_feeds"."username" LIMIT $2`).
+		WithArgs("unknown", 1).
+		WillReturnRows(sqlmock.NewRows(feedCols))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// MuscleGroup is a muscle or a group like the shoulders with the front, side
+// and rear delts below it, exercises refer to it by name.
+type MuscleGroup struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string `gorm:"uniqueIndex"`
+	ParentID  *uint  `gorm:"index"`
+}
+
+// Group returns the id of the parent, 0 for a top level muscle.
+func (m MuscleGroup) Group() uint {
+	if m.ParentID == nil {
+		return 0
+	}
+	return *m.ParentID
+}
+
+// EquipmentType is a kind of equipment, exercises and equipment profiles
+// refer to it by name.
+type EquipmentType struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string `gorm:"uniqueIndex"`
+}
+
+// CatalogInput is a muscle or equipment as entered, parent is only used for
+// muscles.
+type CatalogInput struct {
+	Name   string `form:"name" binding:"required"`
+	Parent uint   `form:"parent"`
+}
+
+var (
+	defaultMuscles = []string{
+		"Abdominals", "Abductors", "Adductors", "Biceps", "Calves", "Chest", "Forearms", "Glutes",
+		"Hamstrings", "Lats", "LowerBack", "Neck", "Quadriceps", "Shoulders", "Traps", "Triceps",
+	}
+	defaultEquipment = []string{
+		"Bands", "Barbell", "Bench", "Body", "Cable", "Dumbbells", "Kettlebells", "Machine", "Other",
+	}
+)
+
+// Catalog holds the muscles and equipment in memory, it is reloaded after
+// every change so listing and validating them needs no query.
+type Catalog struct {
+	sync.RWMutex
+	// muscles are ordered by name with the children after their parent
+	muscles   []MuscleGroup
+	equipment []EquipmentType
+}
+
+func newCatalog(muscles, equipment []string) *Catalog {
+	c := &Catalog{}
+	groups := make([]MuscleGroup, len(muscles))
+	for i, name := range muscles {
+		groups[i] = MuscleGroup{ID: uint(i + 1), Name: name}
+	}
+	types := make([]EquipmentType, len(equipment))
+	for i, name := range equipment {
+		types[i] = EquipmentType{ID: uint(i + 1), Name: name}
+	}
+	c.set(groups, types)
+	return c
+}
+
+// set replaces the catalog, muscles and equipment are expected ordered by
+// name.
+func (c *Catalog) set(muscles []MuscleGroup, equipment []EquipmentType) {
+	ordered := make([]MuscleGroup, 0, len(muscles))
+	for _, muscle := range muscles {
+		if muscle.ParentID != nil {
+			continue
+		}
+		ordered = append(ordered, muscle)
+		for _, child := range muscles {
+			if child.ParentID != nil && *child.ParentID == muscle.ID {
+				ordered = append(ordered, child)
+			}
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.muscles = ordered
+	c.equipment = equipment
+}
+
+func (c *Catalog) muscleNames() []string {
+	c.RLock()
+	defer c.RUnlock()
+	names := make([]string, len(c.muscles))
+	for i, muscle := range c.muscles {
+		names[i] = muscle.Name
+	}
+	return names
+}
+
+func (c *Catalog) equipmentNames() []string {
+	c.RLock()
+	defer c.RUnlock()
+	names := make([]string, len(c.equipment))
+	for i, equipment := range c.equipment {
+		names[i] = equipment.Name
+	}
+	return names
+}
+
+// muscle returns the muscle group with the id.
+func (c *Catalog) muscle(id uint) (MuscleGroup, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	idx := slices.IndexFunc(c.muscles, func(m MuscleGroup) bool { return m.ID == id })
+	if idx < 0 {
+		return MuscleGroup{}, false
+	}
+	return c.muscles[idx], true
+}
+
+// parentName returns the name of the group of the muscle, empty for a top
+// level muscle.
+func (c *Catalog) parentName(name string) string {
+	c.RLock()
+	defer c.RUnlock()
+	idx := slices.IndexFunc(c.muscles, func(m MuscleGroup) bool { return m.Name == name })
+	if idx < 0 || c.muscles[idx].ParentID == nil {
+		return ""
+	}
+	parent := *c.muscles[idx].ParentID
+	idx = slices.IndexFunc(c.muscles, func(m MuscleGroup) bool { return m.ID == parent })
+	if idx < 0 {
+		return ""
+	}
+	return c.muscles[idx].Name
+}
+
+// withChildren adds the muscles below the selected groups, so selecting the
+// shoulders also matches the delts.
+func (c *Catalog) withChildren(muscles []Muscle) []Muscle {
+	c.RLock()
+	defer c.RUnlock()
+	out := slices.Clone(muscles)
+	for _, muscle := range c.muscles {
+		if muscle.ParentID == nil || slices.Contains(out, Muscle(muscle.Name)) {
+			continue
+		}
+		idx := slices.IndexFunc(c.muscles, func(m MuscleGroup) bool { return m.ID == *muscle.ParentID })
+		if idx >= 0 && slices.Contains(muscles, Muscle(c.muscles[idx].Name)) {
+			out = append(out, Muscle(muscle.Name))
+		}
+	}
+	return out
+}
+
+// loadCatalog reads the muscles and equipment into the catalog.
+func (a *App) loadCatalog() error {
+	muscles, err := gorm.G[MuscleGroup](a.db).Order("name").Find(*a.ctx)
+	if err != nil {
+		return err
+	}
+	equipment, err := gorm.G[EquipmentType](a.db).Order("name").Find(*a.ctx)
+	if err != nil {
+		return err
+	}
+	a.catalog.set(muscles, equipment)
+	return nil
+}
+
+// seedCatalog fills the empty tables with the muscles and equipment that used
+// to be built in.
+func seedCatalog(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&MuscleGroup{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		muscles := make([]MuscleGroup, len(defaultMuscles))
+		for i, name := range defaultMuscles {
+			muscles[i] = MuscleGroup{Name: name}
+		}
+		if err := db.Create(&muscles).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Model(&EquipmentType{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		equipment := make([]EquipmentType, len(defaultEquipment))
+		for i, name := range defaultEquipment {
+			equipment[i] = EquipmentType{Name: name}
+		}
+		return db.Create(&equipment).Error
+	}
+	// installs from before the body weight was protected may have lost it
+	return db.Where(EquipmentType{Name: string(BodyWeight)}).FirstOrCreate(&EquipmentType{}).Error
+}
+
+// bindCatalogInput binds the form with the name trimmed, a name of spaces
+// only is missing.
+func bindCatalogInput(c *gin.Context) (CatalogInput, error) {
+	var input CatalogInput
+	err := c.ShouldBindWith(&input, binding.Form)
+	input.Name = strings.TrimSpace(input.Name)
+	if err == nil {
+		err = binding.Validator.ValidateStruct(&input)
+	}
+	if err != nil {
+		return input, fieldErrors(err, input)
+	}
+	return input, nil
+}
+
+func (a *App) ListCatalog(c *gin.Context) {
+	a.renderCatalog(c, nil)
+}
+
+// SaveMuscle adds a muscle or renames and moves an existing one, a rename is
+// applied to all exercises, their revisions, volume targets and presets using
+// the muscle.
+func (a *App) SaveMuscle(c *gin.Context) {
+	input, err := bindCatalogInput(c)
+	if err != nil {
+		log.Printf("bind error: %v", err)
+		a.renderCatalog(c, err)
+		return
+	}
+
+	err = a.saveMuscle(c.Param("id"), input)
+	if err == nil {
+		err = a.loadCatalog()
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderCatalog(c, err)
+}
+
+func (a *App) saveMuscle(id string, input CatalogInput) error {
+	var parentID *uint
+	if input.Parent != 0 {
+		parent, ok := a.catalog.muscle(input.Parent)
+		if !ok || parent.ParentID != nil {
+			return errors.New("muscles can only be grouped below a top level muscle")
+		}
+		parentID = &parent.ID
+	}
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if id == "" {
+			return gorm.G[MuscleGroup](tx).Create(*a.ctx, &MuscleGroup{Name: input.Name, ParentID: parentID})
+		}
+
+		muscle, err := gorm.G[MuscleGroup](tx).Where("id = ?", id).First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		if parentID != nil {
+			if *parentID == muscle.ID {
+				return errors.New("a muscle can not be grouped below itself")
+			}
+			children, err := gorm.G[MuscleGroup](tx).Where("parent_id = ?", muscle.ID).Count(*a.ctx, "id")
+			if err != nil {
+				return err
+			}
+			if children > 0 {
+				return errors.New("muscle '" + muscle.Name + "' has muscles grouped below it")
+			}
+		}
+		_, err = gorm.G[MuscleGroup](tx).
+			Where("id = ?", muscle.ID).
+			Select("name", "parent_id").
+			Updates(*a.ctx, MuscleGroup{Name: input.Name, ParentID: parentID})
+		if err != nil || muscle.Name == input.Name {
+			return err
+		}
+
+		err = tx.Exec(`UPDATE exercises SET primary_muscle = ? WHERE primary_muscle = ?`, input.Name, muscle.Name).Error
+		if err == nil {
+			err = tx.Exec(`UPDATE volume_targets SET muscle = ? WHERE muscle = ?`, input.Name, muscle.Name).Error
+		}
+		if err == nil {
+			err = renameInList(tx, "exercises", "secondary_muscles", muscle.Name, input.Name)
+		}
+		if err == nil {
+			err = renameInRevisions(tx, "PrimaryMuscle", muscle.Name, input.Name)
+		}
+		if err == nil {
+			err = renameInRevisionLists(tx, "SecondaryMuscles", muscle.Name, input.Name)
+		}
+		if err == nil {
+			err = renameInPresets(tx, []string{"primary", "secondary"}, muscle.Name, input.Name)
+		}
+		return err
+	})
+}
+
+// DeleteMuscle removes a muscle no exercise, volume target or preset uses and
+// no muscle is grouped below.
+func (a *App) DeleteMuscle(c *gin.Context) {
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		muscle, err := gorm.G[MuscleGroup](tx).Where("id = ?", c.Param("id")).First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		children, err := gorm.G[MuscleGroup](tx).Where("parent_id = ?", muscle.ID).Count(*a.ctx, "id")
+		if err != nil {
+			return err
+		}
+		if children > 0 {
+			return errors.New("muscle '" + muscle.Name + "' has muscles grouped below it")
+		}
+		used, err := gorm.G[Exercise](tx).
+			Where("primary_muscle = ? OR secondary_muscles @> jsonb_build_array(?::text)", muscle.Name, muscle.Name).
+			Count(*a.ctx, "id")
+		if err != nil {
+			return err
+		}
+		if used > 0 {
+			return errors.New("muscle '" + muscle.Name + "' is used by exercises")
+		}
+		targets, err := gorm.G[VolumeTarget](tx).Where("muscle = ?", muscle.Name).Count(*a.ctx, "id")
+		if err != nil {
+			return err
+		}
+		if targets > 0 {
+			return errors.New("muscle '" + muscle.Name + "' has weekly volume targets")
+		}
+		inPreset, err := presetsUsing(tx, []string{"primary", "secondary"}, muscle.Name)
+		if err != nil {
+			return err
+		}
+		if inPreset {
+			return errors.New("muscle '" + muscle.Name + "' is used by filter presets")
+		}
+		_, err = gorm.G[MuscleGroup](tx).Where("id = ?", muscle.ID).Delete(*a.ctx)
+		return err
+	})
+	if err == nil {
+		err = a.loadCatalog()
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderCatalog(c, err)
+}
+
+// SaveEquipment adds an equipment type or renames an existing one, a rename
+// is applied to all exercises, their revisions, equipment profiles and
+// presets using it. The body weight keeps its name, it is referred to in
+// code.
+func (a *App) SaveEquipment(c *gin.Context) {
+	input, err := bindCatalogInput(c)
+	if err != nil {
+		log.Printf("bind error: %v", err)
+		a.renderCatalog(c, err)
+		return
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		id := c.Param("id")
+		if id == "" {
+			return gorm.G[EquipmentType](tx).Create(*a.ctx, &EquipmentType{Name: input.Name})
+		}
+
+		equipment, err := gorm.G[EquipmentType](tx).Where("id = ?", id).First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		if Equipment(equipment.Name) == BodyWeight && input.Name != equipment.Name {
+			return errors.New("equipment '" + equipment.Name + "' is the body weight and can not be renamed")
+		}
+		_, err = gorm.G[EquipmentType](tx).Where("id = ?", equipment.ID).Update(*a.ctx, "name", input.Name)
+		if err != nil || equipment.Name == input.Name {
+			return err
+		}
+
+		err = renameInList(tx, "exercises", "equipment", equipment.Name, input.Name)
+		if err == nil {
+			err = renameInList(tx, "equipment_profiles", "equipment", equipment.Name, input.Name)
+		}
+		if err == nil {
+			err = renameInRevisionLists(tx, "Equipment", equipment.Name, input.Name)
+		}
+		if err == nil {
+			err = renameInPresets(tx, []string{"equipment"}, equipment.Name, input.Name)
+		}
+		return err
+	})
+	if err == nil {
+		err = a.loadCatalog()
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderCatalog(c, err)
+}
+
+// DeleteEquipment removes an equipment type no exercise, equipment profile or
+// preset uses, the body weight is never removed.
+func (a *App) DeleteEquipment(c *gin.Context) {
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		equipment, err := gorm.G[EquipmentType](tx).Where("id = ?", c.Param("id")).First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		if Equipment(equipment.Name) == BodyWeight {
+			return errors.New("equipment '" + equipment.Name + "' is the body weight and can not be deleted")
+		}
+		used, err := gorm.G[Exercise](tx).
+			Where("equipment @> jsonb_build_array(?::text)", equipment.Name).
+			Count(*a.ctx, "id")
+		if err == nil && used == 0 {
+			used, err = gorm.G[EquipmentProfile](tx).
+				Where("equipment @> jsonb_build_array(?::text)", equipment.Name).
+				Count(*a.ctx, "id")
+		}
+		if err != nil {
+			return err
+		}
+		if used > 0 {
+			return errors.New("equipment '" + equipment.Name + "' is used by exercises or locations")
+		}
+		inPreset, err := presetsUsing(tx, []string{"equipment"}, equipment.Name)
+		if err != nil {
+			return err
+		}
+		if inPreset {
+			return errors.New("equipment '" + equipment.Name + "' is used by filter presets")
+		}
+		_, err = gorm.G[EquipmentType](tx).Where("id = ?", equipment.ID).Delete(*a.ctx)
+		return err
+	})
+	if err == nil {
+		err = a.loadCatalog()
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderCatalog(c, err)
+}
+
+// renameInList replaces the name in the jsonb array column.
+func renameInList(tx *gorm.DB, table, column, old, name string) error {
+	return tx.Exec(`UPDATE `+table+` SET `+column+` = (
+			SELECT jsonb_agg(CASE WHEN elem = ? THEN ? ELSE elem END ORDER BY idx)
+			FROM jsonb_array_elements_text(`+column+`) WITH ORDINALITY AS list(elem, idx)
+		) WHERE `+column+` @> jsonb_build_array(?::text)`, old, name, old).Error
+}
+
+// renameInRevisions replaces the name in the field of the exercise
+// snapshots, so a revert restores the renamed value.
+func renameInRevisions(tx *gorm.DB, field, old, name string) error {
+	return tx.Exec(`UPDATE exercise_revisions SET snapshot = jsonb_set(snapshot, ARRAY[?::text], to_jsonb(?::text))
+		WHERE snapshot->>? = ?`, field, name, field, old).Error
+}
+
+// renameInRevisionLists replaces the name in the list field of the exercise
+// snapshots like renameInList.
+func renameInRevisionLists(tx *gorm.DB, field, old, name string) error {
+	return tx.Exec(`UPDATE exercise_revisions SET snapshot = jsonb_set(snapshot, ARRAY[?::text], (
+			SELECT jsonb_agg(CASE WHEN elem = ? THEN ? ELSE elem END ORDER BY idx)
+			FROM jsonb_array_elements_text(snapshot->?) WITH ORDINALITY AS list(elem, idx)
+		)) WHERE snapshot->? @> jsonb_build_array(?::text)`, field, old, name, field, field, old).Error
+}
+
+// renameInPresets replaces the name in the saved filters for the keys.
+func renameInPresets(tx *gorm.DB, keys []string, old, name string) error {
+	return rewritePresets(tx, func(key, value string) string {
+		if value == old && slices.Contains(keys, key) {
+			return name
+		}
+		return value
+	})
+}
+
+// rewritePresets replaces every value of the saved filter queries by the
+// result of rewrite.
+func rewritePresets(tx *gorm.DB, rewrite func(key, value string) string) error {
+	var presets []ExercisePreset
+	if err := tx.Find(&presets).Error; err != nil {
+		return err
+	}
+	for _, preset := range presets {
+		values, err := url.ParseQuery(preset.Query)
+		if err != nil {
+			continue
+		}
+		for key := range values {
+			for i, value := range values[key] {
+				values[key][i] = rewrite(key, value)
+			}
+		}
+		query := values.Encode()
+		if query == preset.Query {
+			continue
+		}
+		if err := tx.Model(&preset).Update("query", query).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// presetsUsing tells whether a saved filter has the name for one of the keys,
+// those presets would no longer bind after a delete.
+func presetsUsing(tx *gorm.DB, keys []string, name string) (bool, error) {
+	var presets []ExercisePreset
+	if err := tx.Find(&presets).Error; err != nil {
+		return false, err
+	}
+	for _, preset := range presets {
+		values, err := url.ParseQuery(preset.Query)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if slices.Contains(values[key], name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (a *App) renderCatalog(c *gin.Context, err error) {
+	a.catalog.RLock()
+	data := map[string]any{
+		"Muscles":   slices.Clone(a.catalog.muscles),
+		"Equipment": slices.Clone(a.catalog.equipment),
+	}
+	a.catalog.RUnlock()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/catalog.html").
+		SetData(data).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
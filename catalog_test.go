@@ -0,0 +1,329 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	muscleCols    = []string{"ID", "CreatedAt", "UpdatedAt", "Name", "ParentID"}
+	equipmentCols = []string{"ID", "CreatedAt", "UpdatedAt", "Name"}
+)
+
+// expectCatalog expects the catalog to be reloaded with the muscles and
+// equipment.
+func expectCatalog(muscles [][]driver.Value, equipment ...string) {
+	muscleRows := sqlmock.NewRows(muscleCols)
+	for _, muscle := range muscles {
+		muscleRows.AddRow(muscle...)
+	}
+	mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" ORDER BY name`).WillReturnRows(muscleRows)
+	equipmentRows := sqlmock.NewRows(equipmentCols)
+	for i, name := range equipment {
+		equipmentRows.AddRow(i+1, t1, t1, name)
+	}
+	mocksql.ExpectQuery(`SELECT * FROM "equipment_types" ORDER BY name`).WillReturnRows(equipmentRows)
+}
+
+func TestCatalogWithChildren(t *testing.T) {
+	shoulders := uint(2)
+	c := &Catalog{}
+	c.set([]MuscleGroup{
+		{ID: 1, Name: "Chest"},
+		{ID: 4, Name: "Rear Delts", ParentID: &shoulders},
+		{ID: 2, Name: "Shoulders"},
+		{ID: 3, Name: "Front Delts", ParentID: &shoulders},
+	}, nil)
+
+	assert.Equal(t, []string{"Chest", "Shoulders", "Rear Delts", "Front Delts"}, c.muscleNames())
+	assert.Equal(t, []Muscle{"Shoulders", "Rear Delts", "Front Delts"}, c.withChildren([]Muscle{"Shoulders"}))
+	assert.Equal(t, []Muscle{"Chest", "Rear Delts"}, c.withChildren([]Muscle{"Chest", "Rear Delts"}))
+}
+
+func TestCatalog(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	muscles := [][]driver.Value{
+		{1, t1, t1, "Chest", nil},
+		{3, t1, t1, "Rear Delts", 2},
+		{2, t1, t1, "Shoulders", nil},
+	}
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"GET", "/catalog", nil,
+			func() {},
+			"./fixtures/catalog/list.html",
+		},
+		{
+			"POST", "/catalog/muscle", url.Values{"name": {" Rear Delts "}, "parent": {"14"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`INSERT INTO "muscle_groups" ("created_at","updated_at","name","parent_id") VALUES ($1,$2,$3,$4) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Rear Delts", 14).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				mocksql.ExpectCommit()
+				expectCatalog(muscles, "Bench")
+			},
+			"./fixtures/catalog/muscle_add.html",
+		},
+		{
+			"POST", "/catalog/muscle/2", url.Values{"name": {"Delts"}, "parent": {"0"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" WHERE id = $1 ORDER BY "muscle_groups"."id" LIMIT $2`).
+					WithArgs("2", 1).
+					WillReturnRows(sqlmock.NewRows(muscleCols).AddRow(muscles[2]...))
+				mocksql.ExpectExec(`UPDATE "muscle_groups" SET "updated_at"=$1,"name"=$2,"parent_id"=$3 WHERE id = $4`).
+					WithArgs(sqlmock.AnyArg(), "Delts", nil, 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE exercises SET primary_muscle = $1 WHERE primary_muscle = $2`).
+					WithArgs("Delts", "Shoulders").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE volume_targets SET muscle = $1 WHERE muscle = $2`).
+					WithArgs("Delts", "Shoulders").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE exercises SET secondary_muscles = (
+						SELECT jsonb_agg(CASE WHEN elem = $1 THEN $2 ELSE elem END ORDER BY idx)
+						FROM jsonb_array_elements_text(secondary_muscles) WITH ORDINALITY AS list(elem, idx)
+					) WHERE secondary_muscles @> jsonb_build_array($3::text)`).
+					WithArgs("Shoulders", "Delts", "Shoulders").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mocksql.ExpectExec(`UPDATE exercise_revisions SET snapshot = jsonb_set(snapshot, ARRAY[$1::text], to_jsonb($2::text))
+						WHERE snapshot->>$3 = $4`).
+					WithArgs("PrimaryMuscle", "Delts", "PrimaryMuscle", "Shoulders").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE exercise_revisions SET snapshot = jsonb_set(snapshot, ARRAY[$1::text], (
+						SELECT jsonb_agg(CASE WHEN elem = $2 THEN $3 ELSE elem END ORDER BY idx)
+						FROM jsonb_array_elements_text(snapshot->$4) WITH ORDINALITY AS list(elem, idx)
+					)) WHERE snapshot->$5 @> jsonb_build_array($6::text)`).
+					WithArgs("SecondaryMuscles", "Shoulders", "Delts", "SecondaryMuscles", "SecondaryMuscles", "Shoulders").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets"`).
+					WillReturnRows(sqlmock.NewRows(presetCols).
+						AddRow(1, t1, t1, "alice", "Shoulders", "primary=Shoulders&search=Shoulders").
+						AddRow(2, t1, t1, "alice", "Chest", "primary=Chest"))
+				mocksql.ExpectExec(`UPDATE "exercise_presets" SET "query"=$1,"updated_at"=$2 WHERE "id" = $3`).
+					WithArgs("primary=Delts&search=Shoulders", sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectCatalog([][]driver.Value{muscles[0], {2, t1, t1, "Delts", nil}, {3, t1, t1, "Rear Delts", 2}}, "Bench")
+			},
+			"./fixtures/catalog/muscle_rename.html",
+		},
+		{
+			"DELETE", "/catalog/muscle/1", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" WHERE id = $1 ORDER BY "muscle_groups"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(muscleCols).AddRow(muscles[0]...))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "muscle_groups" WHERE parent_id = $1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE primary_muscle = $1 OR secondary_muscles @> jsonb_build_array($2::text)`).
+					WithArgs("Chest", "Chest").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+				mocksql.ExpectRollback()
+			},
+			"./fixtures/catalog/muscle_delete_used.html",
+		},
+		{
+			"DELETE", "/catalog/muscle/1", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" WHERE id = $1 ORDER BY "muscle_groups"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(muscleCols).AddRow(muscles[0]...))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "muscle_groups" WHERE parent_id = $1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE primary_muscle = $1 OR secondary_muscles @> jsonb_build_array($2::text)`).
+					WithArgs("Chest", "Chest").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "volume_targets" WHERE muscle = $1`).
+					WithArgs("Chest").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets"`).
+					WillReturnRows(sqlmock.NewRows(presetCols).
+						AddRow(1, t1, t1, "alice", "Legs", "primary=Quadriceps").
+						AddRow(2, t1, t1, "alice", "Push", "secondary=Chest&secondary=Triceps"))
+				mocksql.ExpectRollback()
+			},
+			"./fixtures/catalog/muscle_delete_preset.html",
+		},
+		{
+			"DELETE", "/catalog/muscle/1", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" WHERE id = $1 ORDER BY "muscle_groups"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(muscleCols).AddRow(muscles[0]...))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "muscle_groups" WHERE parent_id = $1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE primary_muscle = $1 OR secondary_muscles @> jsonb_build_array($2::text)`).
+					WithArgs("Chest", "Chest").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "volume_targets" WHERE muscle = $1`).
+					WithArgs("Chest").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectRollback()
+			},
+			"./fixtures/catalog/muscle_delete_target.html",
+		},
+		{
+			"POST", "/catalog/equipment", url.Values{"name": {"   "}},
+			func() {},
+			"./fixtures/catalog/equipment_blank.html",
+		},
+		{
+			"POST", "/catalog/equipment/4", url.Values{"name": {"Bodyweight"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_types" WHERE id = $1 ORDER BY "equipment_types"."id" LIMIT $2`).
+					WithArgs("4", 1).
+					WillReturnRows(sqlmock.NewRows(equipmentCols).AddRow(4, t1, t1, "Body"))
+				mocksql.ExpectRollback()
+			},
+			"./fixtures/catalog/equipment_rename_body.html",
+		},
+		{
+			"DELETE", "/catalog/equipment/4", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_types" WHERE id = $1 ORDER BY "equipment_types"."id" LIMIT $2`).
+					WithArgs("4", 1).
+					WillReturnRows(sqlmock.NewRows(equipmentCols).AddRow(4, t1, t1, "Body"))
+				mocksql.ExpectRollback()
+			},
+			"./fixtures/catalog/equipment_delete_body.html",
+		},
+		{
+			"POST", "/catalog/equipment/1", url.Values{"name": {"Flat Bench"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_types" WHERE id = $1 ORDER BY "equipment_types"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(equipmentCols).AddRow(1, t1, t1, "Bench"))
+				mocksql.ExpectExec(`UPDATE "equipment_types" SET "name"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs("Flat Bench", sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE exercises SET equipment = (
+						SELECT jsonb_agg(CASE WHEN elem = $1 THEN $2 ELSE elem END ORDER BY idx)
+						FROM jsonb_array_elements_text(equipment) WITH ORDINALITY AS list(elem, idx)
+					) WHERE equipment @> jsonb_build_array($3::text)`).
+					WithArgs("Bench", "Flat Bench", "Bench").
+					WillReturnResult(sqlmock.NewResult(0, 4))
+				mocksql.ExpectExec(`UPDATE equipment_profiles SET equipment = (
+						SELECT jsonb_agg(CASE WHEN elem = $1 THEN $2 ELSE elem END ORDER BY idx)
+						FROM jsonb_array_elements_text(equipment) WITH ORDINALITY AS list(elem, idx)
+					) WHERE equipment @> jsonb_build_array($3::text)`).
+					WithArgs("Bench", "Flat Bench", "Bench").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectExec(`UPDATE exercise_revisions SET snapshot = jsonb_set(snapshot, ARRAY[$1::text], (
+						SELECT jsonb_agg(CASE WHEN elem = $2 THEN $3 ELSE elem END ORDER BY idx)
+						FROM jsonb_array_elements_text(snapshot->$4) WITH ORDINALITY AS list(elem, idx)
+					)) WHERE snapshot->$5 @> jsonb_build_array($6::text)`).
+					WithArgs("Equipment", "Bench", "Flat Bench", "Equipment", "Equipment", "Bench").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets"`).
+					WillReturnRows(sqlmock.NewRows(presetCols))
+				mocksql.ExpectCommit()
+				expectCatalog(muscles, "Flat Bench")
+			},
+			"./fixtures/catalog/equipment_rename.html",
+		},
+		{
+			"DELETE", "/catalog/equipment/1", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_types" WHERE id = $1 ORDER BY "equipment_types"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(equipmentCols).AddRow(1, t1, t1, "Flat Bench"))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE equipment @> jsonb_build_array($1::text)`).
+					WithArgs("Flat Bench").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "equipment_profiles" WHERE equipment @> jsonb_build_array($1::text)`).
+					WithArgs("Flat Bench").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets"`).
+					WillReturnRows(sqlmock.NewRows(presetCols).AddRow(1, t1, t1, "alice", "Benches", "equipment=Bench"))
+				mocksql.ExpectExec(`DELETE FROM "equipment_types" WHERE id = $1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectCatalog(muscles)
+			},
+			"./fixtures/catalog/equipment_delete.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestDeleteMuscleKeepsHistory(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/catalog/muscle/1", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT * FROM "muscle_groups" WHERE id = $1 ORDER BY "muscle_groups"."id" LIMIT $2`).
+		WithArgs("1", 1).
+		WillReturnRows(sqlmock.NewRows(muscleCols).AddRow(1, t1, t1, "Abdominals", nil))
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "muscle_groups" WHERE parent_id = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE primary_muscle = $1 OR secondary_muscles @> jsonb_build_array($2::text)`).
+		WithArgs("Abdominals", "Abdominals").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "volume_targets" WHERE muscle = $1`).
+		WithArgs("Abdominals").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercise_presets"`).
+		WillReturnRows(sqlmock.NewRows(presetCols))
+	mocksql.ExpectExec(`DELETE FROM "muscle_groups" WHERE id = $1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	expectCatalog([][]driver.Value{{2, t1, t1, "Chest", nil}}, "Bench")
+	router.ServeHTTP(w, req)
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+
+	// the revisions still name the deleted muscle
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/exercise/1/history", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1 ORDER BY id`).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...).AddRow(revision2...))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/catalog/muscle_deleted_history.html", w)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Enum is a value out of a fixed list of names. It is stored, sent in forms
+// and marshaled as its name, so values can be added or reordered without
+// touching existing data.
+type Enum[N enumNames] string
+
+// enumNames is implemented by an empty struct per enumeration, names returns
+// the values in the order they are listed. Adding a value only needs its name
+// there, constants are declared for the values referenced in code.
+type enumNames interface {
+	names() []string
+}
+
+// catalogNames is implemented by the enumerations kept in the catalog of the
+// app, their values can be deleted at any time. They have no fixed names,
+// fromCatalog returns the current ones.
+type catalogNames interface {
+	enumNames
+	fromCatalog(c *Catalog) []string
+}
+
+func (e Enum[N]) String() string {
+	return string(e)
+}
+
+// Valid reports whether the value is one of the fixed names, values kept in
+// the catalog are checked with validIn.
+func (e Enum[N]) Valid() bool {
+	var n N
+	return slices.Contains(n.names(), string(e))
+}
+
+// validIn reports whether the value is valid, looking up the values kept in
+// the catalog there.
+func (e Enum[N]) validIn(c *Catalog) bool {
+	var n N
+	if names, ok := any(n).(catalogNames); ok {
+		return slices.Contains(names.fromCatalog(c), string(e))
+	}
+	return e.Valid()
+}
+
+func (e Enum[N]) MarshalText() ([]byte, error) {
+	return []byte(e), nil
+}
+
+// UnmarshalText parses a name. Names of the catalog are taken as they are
+// like when scanned, stored data like revision snapshots can name a deleted
+// value, the enum validation rejects them where they are used.
+func (e *Enum[N]) UnmarshalText(text []byte) error {
+	var n N
+	if _, ok := any(n).(catalogNames); ok {
+		*e = Enum[N](text)
+		return nil
+	}
+	value, err := parseEnum[N](string(text))
+	if err != nil {
+		return err
+	}
+	*e = value
+	return nil
+}
+
+func (e Enum[N]) Value() (driver.Value, error) {
+	return string(e), nil
+}
+
+func (e *Enum[N]) Scan(src any) error {
+	switch src := src.(type) {
+	case string:
+		*e = Enum[N](src)
+	case []byte:
+		*e = Enum[N](src)
+	case nil:
+		*e = ""
+	default:
+		return fmt.Errorf("cannot scan %T into an enum", src)
+	}
+	return nil
+}
+
+// parseEnum returns the value with the name. Like in forms the case has to
+// match.
+func parseEnum[N enumNames](name string) (Enum[N], error) {
+	value := Enum[N](name)
+	if !value.Valid() {
+		return "", fmt.Errorf("'%s' is not a valid choice", name)
+	}
+	return value, nil
+}
+
+// enumValues lists all values of the enumeration.
+func enumValues[N enumNames]() []Enum[N] {
+	var n N
+	names := n.names()
+	values := make([]Enum[N], len(names))
+	for i, name := range names {
+		values[i] = Enum[N](name)
+	}
+	return values
+}
+
+// catalogValues lists the values of the enumeration kept in the catalog.
+func catalogValues[N catalogNames](c *Catalog) []Enum[N] {
+	var n N
+	names := n.fromCatalog(c)
+	values := make([]Enum[N], len(names))
+	for i, name := range names {
+		values[i] = Enum[N](name)
+	}
+	return values
+}
+
+// legacyEnum is an enumeration from when the exercises stored values as
+// their position, names must stay in the original order.
+type legacyEnum struct {
+	names []string
+	// column or jsonb array column of the exercises
+	column, list string
+}
+
+var legacyEnums = []legacyEnum{
+	{names: []string{"Pull", "Push", "Static"}, column: "force"},
+	{names: []string{"Easy", "Middle", "Hard"}, column: "level"},
+	{names: []string{"Compound", "Isolation"}, column: "mechanic"},
+	{names: []string{"Endurance", "Strength", "Stretching"}, column: "category"},
+	{
+		names: []string{
+			"Abdominals", "Abductors", "Adductors", "Biceps", "Calves", "Chest", "Forearms", "Glutes",
+			"Hamstrings", "Lats", "LowerBack", "Neck", "Quadriceps", "Shoulders", "Traps", "Triceps",
+		},
+		column: "primary_muscle", list: "secondary_muscles",
+	},
+	{names: []string{"Bands", "Barbell", "Bench", "Body", "Cable", "Dumbbells", "Kettlebells", "Machine", "Other"}, list: "equipment"},
+}
+
+// sqlArray returns the names as a postgres text array.
+func (e legacyEnum) sqlArray() string {
+	quoted := make([]string, len(e.names))
+	for i, name := range e.names {
+		quoted[i] = "'" + name + "'"
+	}
+	return "ARRAY[" + strings.Join(quoted, ",") + "]"
+}
+
+// listSQL converts the positions in the jsonb array column to names.
+func (e legacyEnum) listSQL() string {
+	return `(SELECT COALESCE(jsonb_agg((` + e.sqlArray() + `)[elem::int + 1] ORDER BY idx), '[]'::jsonb)
+		FROM jsonb_array_elements_text(` + e.list + `) WITH ORDINALITY AS list(elem, idx))`
+}
+
+// migrateEnums converts enumerations of the exercises stored as their
+// position to names, it has to run before the auto migration changes the
+// column types.
+func migrateEnums(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Exercise{}) {
+		return nil
+	}
+	columnTypes, err := db.Migrator().ColumnTypes(&Exercise{})
+	if err != nil {
+		return err
+	}
+	numeric := map[string]bool{}
+	for _, columnType := range columnTypes {
+		numeric[columnType.Name()] = strings.HasPrefix(strings.ToLower(columnType.DatabaseTypeName()), "int")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, e := range legacyEnums {
+			if numeric[e.column] {
+				err := tx.Exec(`ALTER TABLE exercises ALTER COLUMN ` + e.column +
+					` TYPE text USING (` + e.sqlArray() + `)[` + e.column + ` + 1]`).Error
+				if err != nil {
+					return err
+				}
+			}
+			if e.list != "" {
+				err := tx.Exec(`UPDATE exercises SET ` + e.list + ` = ` + e.listSQL() +
+					` WHERE jsonb_typeof(` + e.list + `->0) = 'number'`).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
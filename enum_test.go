@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnum(t *testing.T) {
+	assert.True(t, Push.Valid())
+	assert.False(t, Force("Sideways").Valid())
+	assert.False(t, Force("").Valid())
+	assert.Equal(t, []Force{Pull, Push, Static}, enumValues[forceNames]())
+
+	data, err := json.Marshal(Exercise{Force: Push, SecondaryMuscles: []Muscle{"Chest", "LowerBack"}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Force":"Push"`)
+	assert.Contains(t, string(data), `"SecondaryMuscles":["Chest","LowerBack"]`)
+
+	var exercise Exercise
+	assert.NoError(t, json.Unmarshal([]byte(`{"Force":"Static","Equipment":["Bench"]}`), &exercise))
+	assert.Equal(t, Static, exercise.Force)
+	assert.Equal(t, []Equipment{"Bench"}, exercise.Equipment)
+	assert.EqualError(t, json.Unmarshal([]byte(`{"Force":"Sideways"}`), &exercise), "'Sideways' is not a valid choice")
+	// forms reject the wrong case too
+	assert.EqualError(t, json.Unmarshal([]byte(`{"Force":"static"}`), &exercise), "'static' is not a valid choice")
+	// a deleted muscle is decoded and rejected by the validation
+	assert.NoError(t, json.Unmarshal([]byte(`{"PrimaryMuscle":"Delts"}`), &exercise))
+	catalog := newCatalog(defaultMuscles, defaultEquipment)
+	assert.False(t, exercise.PrimaryMuscle.validIn(catalog))
+	assert.True(t, Muscle("Chest").validIn(catalog))
+	assert.True(t, Push.validIn(catalog))
+
+	var level Level
+	assert.NoError(t, level.Scan([]byte("Hard")))
+	assert.Equal(t, Hard, level)
+	value, err := level.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hard", value)
+}
+
+func TestLegacyEnumSQL(t *testing.T) {
+	assert.Equal(t, "ARRAY['Pull','Push','Static']", legacyEnums[0].sqlArray())
+	assert.Equal(t, `(SELECT COALESCE(jsonb_agg((ARRAY['Bands','Barbell','Bench','Body','Cable','Dumbbells','Kettlebells','Machine','Other'])[elem::int + 1] ORDER BY idx), '[]'::jsonb)
+		FROM jsonb_array_elements_text(equipment) WITH ORDINALITY AS list(elem, idx))`, legacyEnums[5].listSQL())
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EquipmentProfile is the equipment available at a training location.
+type EquipmentProfile struct {
+	ID                 uint
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Username           string      `gorm:"uniqueIndex:idx_equipment_profiles_username_name"`
+	Name               string      `form:"name" binding:"required" gorm:"uniqueIndex:idx_equipment_profiles_username_name"`
+	Equipment          []Equipment `form:"equipment" binding:"dive,enum" gorm:"type:jsonb;serializer:json"`
+	PlateIncrements    []float64   `gorm:"type:jsonb;serializer:json"`
+	DumbbellIncrements []float64   `gorm:"type:jsonb;serializer:json"`
+}
+
+// ProfileInput holds the increments as entered, a comma separated list of
+// weights.
+type ProfileInput struct {
+	Plates    string `form:"plates"`
+	Dumbbells string `form:"dumbbells"`
+}
+
+func (a *App) ListProfiles(c *gin.Context) {
+	a.renderProfiles(c, EquipmentProfile{}, ProfileInput{}, nil)
+}
+
+// SaveProfile creates the profile, saving under an existing name replaces it.
+func (a *App) SaveProfile(c *gin.Context) {
+	var profile EquipmentProfile
+	var input ProfileInput
+	err := c.ShouldBindWith(&profile, binding.Form)
+	if err == nil {
+		err = c.ShouldBindWith(&input, binding.Form)
+	}
+	if err == nil {
+		profile.PlateIncrements, err = parseIncrements(input.Plates)
+	}
+	if err == nil {
+		profile.DumbbellIncrements, err = parseIncrements(input.Dumbbells)
+	}
+	if err != nil {
+		log.Printf("bind error: %v", err)
+		a.renderProfiles(c, profile, input, err)
+		return
+	}
+
+	profile.Username = currentUser(c)
+	err = gorm.G[EquipmentProfile](a.db, clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "equipment", "plate_increments", "dumbbell_increments"}),
+	}).Create(*a.ctx, &profile)
+	if err != nil {
+		log.Printf("db error: %v", err)
+		a.renderProfiles(c, profile, input, err)
+		return
+	}
+	a.renderProfiles(c, EquipmentProfile{}, ProfileInput{}, nil)
+}
+
+func (a *App) DeleteProfile(c *gin.Context) {
+	_, err := gorm.G[EquipmentProfile](a.db).
+		Where("id = ? AND username = ?", c.Param("id"), currentUser(c)).
+		Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderProfiles(c, EquipmentProfile{}, ProfileInput{}, err)
+}
+
+func (a *App) renderProfiles(c *gin.Context, profile EquipmentProfile, input ProfileInput, err error) {
+	data := map[string]any{
+		"Profiles":       a.listProfiles(c),
+		"Input":          profile,
+		"Increments":     input,
+		"PossibleValues": a.possibleValues(),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/equipment.html").
+		SetData(data).
+		AddTemplateFunction("has", has).
+		AddTemplateFunction("join", join).
+		AddTemplateFunction("increments", formatIncrements).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+func (a *App) listProfiles(c *gin.Context) []EquipmentProfile {
+	profiles, err := gorm.G[EquipmentProfile](a.db).
+		Where("username = ?", currentUser(c)).
+		Order("name").
+		Find(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	return profiles
+}
+
+// locationEquipment returns the equipment of the users profile, the empty
+// id means all equipment is available.
+func (a *App) locationEquipment(c *gin.Context, id uint) ([]Equipment, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	profile, err := gorm.G[EquipmentProfile](a.db).
+		Where("id = ? AND username = ?", id, currentUser(c)).
+		First(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Equipment == nil {
+		return []Equipment{}, nil
+	}
+	return profile.Equipment, nil
+}
+
+// parseIncrements parses a comma separated list of positive weights and
+// returns them sorted.
+func parseIncrements(s string) ([]float64, error) {
+	increments := []float64{}
+	for field := range strings.SplitSeq(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		increment, err := strconv.ParseFloat(field, 64)
+		if err != nil || increment <= 0 {
+			return nil, errors.New("invalid increment '" + field + "'")
+		}
+		increments = append(increments, increment)
+	}
+	slices.Sort(increments)
+	return slices.Compact(increments), nil
+}
+
+func formatIncrements(increments []float64) string {
+	fields := make([]string, len(increments))
+	for i, increment := range increments {
+		fields[i] = strconv.FormatFloat(increment, 'f', -1, 64)
+	}
+	return strings.Join(fields, ", ")
+}
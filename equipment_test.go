@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIncrements(t *testing.T) {
+	increments, err := parseIncrements(" 5, 1.25,2.5,, 5 ")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.25, 2.5, 5}, increments)
+	assert.Equal(t, "1.25, 2.5, 5", formatIncrements(increments))
+
+	_, err = parseIncrements("2.5, -1")
+	assert.EqualError(t, err, "invalid increment '-1'")
+	_, err = parseIncrements("a")
+	assert.EqualError(t, err, "invalid increment 'a'")
+}
+
+func TestProfiles(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"GET", "/equipment/list", nil,
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(profileCols).AddRow(profile1...))
+			},
+			"./fixtures/equipment/list.html",
+		},
+		{
+			"POST", "/equipment",
+			url.Values{"name": {"Home"}, "equipment": {"Body", "Dumbbells"}, "dumbbells": {"4, 2"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`INSERT INTO "equipment_profiles" ("created_at","updated_at","username","name","equipment","plate_increments","dumbbell_increments")
+						VALUES ($1,$2,$3,$4,$5,$6,$7)
+						ON CONFLICT ("username","name") DO UPDATE SET "updated_at"="excluded"."updated_at","equipment"="excluded"."equipment","plate_increments"="excluded"."plate_increments","dumbbell_increments"="excluded"."dumbbell_increments"
+						RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", "Home", `["Body","Dumbbells"]`, "[]", "[2,4]").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(profileCols).AddRow(profile1...))
+			},
+			"./fixtures/equipment/list.html",
+		},
+		{
+			"POST", "/equipment",
+			url.Values{"name": {"Gym"}, "equipment": {"Barbell"}, "plates": {"1.25, x"}},
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(profileCols).AddRow(profile1...))
+			},
+			"./fixtures/equipment/save_error.html",
+		},
+		{
+			"DELETE", "/equipment/2", nil,
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`DELETE FROM "equipment_profiles" WHERE id = $1 AND username = $2`).
+					WithArgs("2", "alice").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(profileCols))
+			},
+			"./fixtures/equipment/delete.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			req.Header.Set("Remote-User", "alice")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestListExercisesAtLocation(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		dbmocks func()
+		fixture string
+	}{
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE id = $1 AND username = $2 ORDER BY "equipment_profiles"."id" LIMIT $3`).
+					WithArgs(2, "alice", 1).
+					WillReturnRows(sqlmock.NewRows(profileCols).AddRow(profile1...))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE NOT EXISTS (
+						SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+						WHERE elem NOT IN (SELECT unnest($1::text[]))
+					) AND trashed_at IS NULL`).
+					WithArgs(`{"Body","Dumbbells"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE NOT EXISTS (
+						SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+						WHERE elem NOT IN (SELECT unnest($1::text[]))
+					) AND trashed_at IS NULL ORDER BY "id" LIMIT $2`).
+					WithArgs(`{"Body","Dumbbells"}`, 25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+			},
+			"./fixtures/equipment/filter_location.html",
+		},
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE id = $1 AND username = $2 ORDER BY "equipment_profiles"."id" LIMIT $3`).
+					WithArgs(2, "alice", 1).
+					WillReturnError(fmt.Errorf("test profile error"))
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+			},
+			"./fixtures/equipment/filter_location_error.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/exercise/list?location=2", nil)
+			req.Header.Set("HX-Request", "true")
+			req.Header.Set("HX-Target", "table")
+			req.Header.Set("Remote-User", "alice")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
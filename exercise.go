@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
@@ -17,344 +16,262 @@ import (
 	"github.com/donseba/go-htmx"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
-type ExerciseFilter struct {
-	Name            string      `form:"name"`
-	Force           []Force     `form:"force" binding:"required"`
-	Level           []Level     `form:"level" binding:"required"`
-	Mechanic        []Mechanic  `form:"mechanic" binding:"required"`
-	Category        []Category  `form:"category" binding:"required"`
-	PrimaryMuscle   []Muscle    `form:"primary" binding:"required"`
-	SecondaryMuscle []Muscle    `form:"secondary" binding:"required"`
-	Equipment       []Equipment `form:"equipment" binding:"required"`
-}
-
 type Exercise struct {
-	ID               uint
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
-	Name             string      `form:"name" binding:"required"`
-	Force            Force       `form:"force" binding:"number,gte=0"`
-	Level            Level       `form:"level" binding:"number,gte=0"`
-	Mechanic         Mechanic    `form:"mechanic" binding:"number,gte=0"`
-	Category         Category    `form:"category" binding:"number,gte=0"`
-	PrimaryMuscle    Muscle      `form:"primary" binding:"number,gte=0"`
-	SecondaryMuscles []Muscle    `form:"secondary" binding:"required" gorm:"type:jsonb;serializer:json"`
-	Equipment        []Equipment `form:"equipment" binding:"required" gorm:"type:jsonb;serializer:json"`
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Version counts the updates, an edit is only stored if it is based on
+	// the current version
+	Version          uint     `form:"version" gorm:"not null;default:0"`
+	Name             string   `form:"name" binding:"required"`
+	Aliases          []string `form:"aliases" gorm:"type:jsonb;serializer:json"`
+	ParentID         *uint
+	Parent           *Exercise   `form:"-"`
+	ParentName       string      `form:"parent" gorm:"-"`
+	Force            Force       `form:"force,default=Pull" binding:"enum"`
+	Level            Level       `form:"level,default=Easy" binding:"enum"`
+	Mechanic         Mechanic    `form:"mechanic,default=Compound" binding:"enum"`
+	Category         Category    `form:"category,default=Endurance" binding:"enum"`
+	PrimaryMuscle    Muscle      `form:"primary" binding:"enum"`
+	SecondaryMuscles []Muscle    `form:"secondary" binding:"required,dive,enum" gorm:"type:jsonb;serializer:json"`
+	Equipment        []Equipment `form:"equipment" binding:"required,dive,enum" gorm:"type:jsonb;serializer:json"`
 	Instructions     string      `form:"instructions" binding:"required" gorm:"type:text"`
 	Images           []string    `gorm:"type:jsonb;serializer:json"`
+	TrashedAt        *time.Time  `form:"-" gorm:"index"`
 }
 
-type Force uint
+type Force = Enum[forceNames]
 
 const (
-	Pull Force = iota
-	Push
-	Static
-
-	_ForceCount
+	Pull   Force = "Pull"
+	Push   Force = "Push"
+	Static Force = "Static"
 )
 
-var forceName = map[Force]string{
-	Pull:   "Pull",
-	Push:   "Push",
-	Static: "Static",
-}
+type forceNames struct{}
 
-func (f Force) String() string {
-	return forceName[f]
+func (forceNames) names() []string {
+	return []string{"Pull", "Push", "Static"}
 }
 
-type Level uint
+type Level = Enum[levelNames]
 
 const (
-	Easy Level = iota
-	Middle
-	Hard
-
-	_LevelCount
+	Easy   Level = "Easy"
+	Middle Level = "Middle"
+	Hard   Level = "Hard"
 )
 
-var levelName = map[Level]string{
-	Easy:   "Easy",
-	Middle: "Middle",
-	Hard:   "Hard",
-}
+type levelNames struct{}
 
-func (l Level) String() string {
-	return levelName[l]
+func (levelNames) names() []string {
+	return []string{"Easy", "Middle", "Hard"}
 }
 
-type Mechanic uint
+type Mechanic = Enum[mechanicNames]
 
 const (
-	Compound Mechanic = iota
-	Isolation
-
-	_MechanicCount
+	Compound  Mechanic = "Compound"
+	Isolation Mechanic = "Isolation"
 )
 
-var mechanicName = map[Mechanic]string{
-	Compound:  "Compound",
-	Isolation: "Isolation",
-}
+type mechanicNames struct{}
 
-func (m Mechanic) String() string {
-	return mechanicName[m]
+func (mechanicNames) names() []string {
+	return []string{"Compound", "Isolation"}
 }
 
-type Category uint
+type Category = Enum[categoryNames]
 
 const (
-	Endurance Category = iota
-	Strength
-	Stretching
-
-	_CategoryCount
+	Endurance  Category = "Endurance"
+	Strength   Category = "Strength"
+	Stretching Category = "Stretching"
 )
 
-var categoryName = map[Category]string{
-	Endurance:  "Endurance",
-	Strength:   "Strength",
-	Stretching: "Stretching",
-}
+type categoryNames struct{}
 
-func (c Category) String() string {
-	return categoryName[c]
+func (categoryNames) names() []string {
+	return []string{"Endurance", "Strength", "Stretching"}
 }
 
-type Equipment uint
+// Equipment and Muscle are kept in the catalog, see EquipmentType and
+// MuscleGroup. They have no fixed names, the app looks them up in its
+// catalog.
+type Equipment = Enum[equipmentNames]
 
-const (
-	Bands Equipment = iota
-	Barbell
-	Bench
-	Body
-	Cable
-	Dumbbells
-	Kettlebells
-	Machine
-	Other
-
-	_EquipmentCount
-)
+// BodyWeight is always at hand, it is never busy with another exercise.
+const BodyWeight Equipment = "Body"
 
-var equipmentName = map[Equipment]string{
-	Bands:       "Bands",
-	Barbell:     "Barbell",
-	Bench:       "Bench",
-	Body:        "Body",
-	Cable:       "Cable",
-	Dumbbells:   "Dumbbells",
-	Kettlebells: "Kettlebells",
-	Machine:     "Machine",
-	Other:       "Other",
-}
+type equipmentNames struct{}
 
-func (e Equipment) String() string {
-	return equipmentName[e]
-}
-
-func (m Equipment) MarshalJSON() ([]byte, error) {
-	return json.Marshal(uint(m))
-}
-
-func (m *Equipment) UnmarshalJSON(data []byte) error {
-	var v uint
-	if err := json.Unmarshal(data, &v); err != nil {
-		return err
-	}
-	*m = Equipment(v)
+func (equipmentNames) names() []string {
 	return nil
 }
 
-type Muscle uint
-
-const (
-	Abdominals Muscle = iota
-	Abductors
-	Adductors
-	Biceps
-	Calves
-	Chest
-	Forearms
-	Glutes
-	Hamstrings
-	Lats
-	LowerBack
-	Neck
-	Quadriceps
-	Shoulders
-	Traps
-	Triceps
-
-	_MuscleCount
-)
-
-var muscleName = map[Muscle]string{
-	Abdominals: "Abdominals",
-	Abductors:  "Abductors",
-	Adductors:  "Adductors",
-	Biceps:     "Biceps",
-	Calves:     "Calves",
-	Chest:      "Chest",
-	Forearms:   "Forearms",
-	Glutes:     "Glutes",
-	Hamstrings: "Hamstrings",
-	Lats:       "Lats",
-	LowerBack:  "LowerBack",
-	Neck:       "Neck",
-	Quadriceps: "Quadriceps",
-	Shoulders:  "Shoulders",
-	Traps:      "Traps",
-	Triceps:    "Triceps",
+func (equipmentNames) fromCatalog(c *Catalog) []string {
+	return c.equipmentNames()
 }
 
-func (m Muscle) String() string {
-	return muscleName[m]
-}
+type Muscle = Enum[muscleNames]
 
-func (m Muscle) MarshalJSON() ([]byte, error) {
-	return json.Marshal(uint(m))
-}
+type muscleNames struct{}
 
-func (m *Muscle) UnmarshalJSON(data []byte) error {
-	var v uint
-	if err := json.Unmarshal(data, &v); err != nil {
-		return err
-	}
-	*m = Muscle(v)
+func (muscleNames) names() []string {
 	return nil
 }
 
+func (muscleNames) fromCatalog(c *Catalog) []string {
+	return c.muscleNames()
+}
+
 var (
-	possibleValues = map[string]any{
-		"Forces":     allValues[Force](uint(_ForceCount)),
-		"Levels":     allValues[Level](uint(_LevelCount)),
-		"Mechanics":  allValues[Mechanic](uint(_MechanicCount)),
-		"Categories": allValues[Category](uint(_CategoryCount)),
-		"Muscles":    allValues[Muscle](uint(_MuscleCount)),
-		"Equipment":  allValues[Equipment](uint(_EquipmentCount)),
+	exerciseColumns = []string{
+		"Action", "Name", "Force", "Level", "Mechanic", "Category", "Primary", "Secondary", "Equipment", "Instructions", "Images",
+	}
+	exerciseSortColumns = map[string]string{
+		"Name":         "name",
+		"Force":        "force",
+		"Level":        "level",
+		"Mechanic":     "mechanic",
+		"Category":     "category",
+		"Primary":      "primary_muscle",
+		"Secondary":    "secondary_muscles",
+		"Equipment":    "equipment",
+		"Instructions": "instructions",
+		"Images":       "images",
 	}
 )
 
+// possibleValues lists the choices of the exercise fields, muscles and
+// equipment can change at any time.
+func (a *App) possibleValues() map[string]any {
+	return map[string]any{
+		"Forces":     enumValues[forceNames](),
+		"Levels":     enumValues[levelNames](),
+		"Mechanics":  enumValues[mechanicNames](),
+		"Categories": enumValues[categoryNames](),
+		"Muscles":    catalogValues[muscleNames](a.catalog),
+		"Equipment":  catalogValues[equipmentNames](a.catalog),
+		"MatchModes": enumValues[matchModeNames](),
+	}
+}
+
 func (a *App) ListExercises(c *gin.Context) {
-	var exercises []Exercise
-	exercises, err := gorm.G[Exercise](a.db).Order("id").Find(*a.ctx)
-	if err != nil {
-		log.Printf("db error: %v", err)
+	filter, bindErr := bindFilter(c)
+	data := a.filterExercises(c, filter, bindErr)
+	data["Actions"] = []string{"Del", "Edit"}
+	data["ListLink"] = "/exercise/list"
+	if c.GetHeader("HX-Target") == "table" {
+		table := exerciseTable().SetData(data)
+		a.render(c, &table)
+		return
 	}
 
-	data := map[string]any{
-		"Exercises": exercises,
-		"Columns": []string{
-			"Action", "Name", "Force", "Level", "Mechanic", "Category", "Primary", "Secondary", "Equipment", "Instructions", "Images",
-		},
-		"Actions":        []string{"Del", "Edit"},
-		"PossibleValues": possibleValues,
-	}
-	table := htmx.NewComponent("templates/components/exercise_table.html").
-		AddTemplateFunction("exerciseAction", exerciseAction).
-		AddTemplateFunction("join", join)
-	filter := htmx.NewComponent("templates/components/exercise_filter.html")
+	data["Presets"] = a.listPresets(c)
+	data["Profiles"] = a.listProfiles(c)
 	page := htmx.NewComponent("templates/pages/exercises.html").
-		With(table, "Table").
-		With(filter, "Filter").
+		With(exerciseTable(), "Table").
+		With(exerciseFilter(), "Filter").
 		SetData(data).
 		Wrap(mainContent(), "Content")
 	a.render(c, &page)
 }
 
-func (a *App) ListExercisesWithFilter(c *gin.Context) {
+// bindFilter binds the exercise filter of the query. Stale links like presets
+// of a deleted muscle list all exercises with the reason instead.
+func bindFilter(c *gin.Context) (ExerciseFilter, error) {
 	var filter ExerciseFilter
-	var exercises []Exercise
-	if err := c.MustBindWith(&filter, binding.FormMultipart); err != nil {
+	if err := c.ShouldBindWith(&filter, binding.Query); err != nil {
 		log.Printf("bind error: %v", err)
-		return
+		return ExerciseFilter{SecondaryMode: SubsetOf, EquipmentMode: SubsetOf}, fieldErrors(err, filter)
+	}
+	return filter, nil
+}
+
+func (a *App) filterExercises(c *gin.Context, filter ExerciseFilter, bindErr error) map[string]any {
+	sortable := searchSortColumns(&filter.Pagination, filter.Search)
+	available, err := a.locationEquipment(c, filter.Location)
+	if err != nil {
+		log.Printf("db error: %v", err)
+		filter.Location = 0
 	}
-	exercises, err := gorm.G[Exercise](a.db).Order("id").
-		Where("name LIKE ?", "%"+filter.Name+"%").
-		Where(map[string]any{
-			"force":          filter.Force,
-			"level":          filter.Level,
-			"mechanic":       filter.Mechanic,
-			"category":       filter.Category,
-			"primary_muscle": filter.PrimaryMuscle,
-		}).
-		Where(`NOT EXISTS (
-			SELECT 1 FROM jsonb_array_elements(secondary_muscles) elem
-			WHERE (elem::int) NOT IN (SELECT unnest(?::int[]))
-		)`, pq.Array(filter.SecondaryMuscle)).
-		Where(`NOT EXISTS (
-			SELECT 1 FROM jsonb_array_elements(equipment) elem
-			WHERE (elem::int) NOT IN (SELECT unnest(?::int[]))
-		)`, pq.Array(filter.Equipment)).
-		Find(c)
+	filter.available = available
+	exercises, pageInfo, err := paginate(c, filter.apply(a.db, a.catalog), filter.Pagination, sortable)
 	if err != nil {
 		log.Printf("db error: %v", err)
 	}
 
 	data := map[string]any{
-		"Exercises": exercises,
-		"Columns": []string{
-			"Action", "Name", "Force", "Level", "Mechanic", "Category", "Primary", "Secondary", "Equipment", "Instructions", "Images",
-		},
-		"Actions": []string{"Del", "Edit"},
+		"Exercises":      exercises,
+		"Columns":        exerciseColumns,
+		"Sortable":       sortable,
+		"Page":           pageInfo,
+		"Filter":         filter,
+		"PossibleValues": a.possibleValues(),
 	}
-	page := htmx.NewComponent("templates/components/exercise_table.html").
-		SetData(data).
+	if bindErr != nil {
+		data["FilterError"] = "The filter was reset: " + bindErr.Error()
+	}
+	return data
+}
+
+func exerciseTable() htmx.RenderableComponent {
+	return htmx.NewComponent("templates/components/exercise_table.html").
 		AddTemplateFunction("exerciseAction", exerciseAction).
 		AddTemplateFunction("join", join)
-	a.render(c, &page)
+}
+
+func exerciseForm() htmx.RenderableComponent {
+	return htmx.NewComponent("templates/components/exercise_form.html").
+		AddTemplateFunction("fieldError", fieldError)
 }
 
 func (a *App) CreateExercise(c *gin.Context) {
 	data := map[string]any{
-		"PossibleValues": possibleValues,
+		"PossibleValues": a.possibleValues(),
 		"ValidationLink": template.HTMLAttr(`hx-post="/exercise/validate"`),
-		"Input":          Exercise{},
-		"Button":         "Create",
+		// the first value of each fixed choice is preselected
+		"Input":  Exercise{Force: Pull, Level: Easy, Mechanic: Compound, Category: Endurance},
+		"Button": "Create",
 	}
-	page := htmx.NewComponent("templates/components/exercise_form.html").SetData(data).Wrap(mainContent(), "Content")
+	page := exerciseForm().SetData(data).Wrap(mainContent(), "Content")
 	a.render(c, &page)
 }
 
 func (a *App) ReadExercise(c *gin.Context) {
 	id := c.Param("id")
 	log.Printf("id: %v+", id)
-	exercise, err := gorm.G[Exercise](a.db).Where("id = ?", id).First(*a.ctx)
+	exercise, err := gorm.G[Exercise](a.db).Preload("Parent", nil).Where("id = ?", id).First(*a.ctx)
 	if err != nil {
 		log.Printf("db error: %v", err)
 	} else {
 		err = errors.New("")
 	}
+	if exercise.Parent != nil {
+		exercise.ParentName = exercise.Parent.Name
+	}
 
 	data := map[string]any{
-		"PossibleValues": possibleValues,
+		"PossibleValues": a.possibleValues(),
 		"ValidationLink": template.HTMLAttr(`hx-post="/exercise/` + id + `/validate"`),
 		"Input":          exercise,
 		"Error":          err.Error(),
 		"Button":         "Update",
 	}
-	page := htmx.NewComponent("templates/components/exercise_form.html").SetData(data).Wrap(mainContent(), "Content")
-	a.render(c, &page)
-}
-
-func (a *App) DeleteExercise(c *gin.Context) {
-	id := c.Param("id")
-	exercise, err := gorm.G[Exercise](a.db).Where("id = ?", id).First(*a.ctx)
-	if err != nil {
-		log.Printf("db error: %v", err)
-	}
-	a.deleteImages(exercise.Images)
-	_, err = gorm.G[Exercise](a.db).Where("id = ?", id).Delete(*a.ctx)
-	if err != nil {
-		log.Printf("db error: %v", err)
+	if exercise.ID != 0 {
+		data["SubstitutesLink"] = "/exercise/" + id + "/substitutes"
+		data["VariationsLink"] = "/exercise/" + id + "/variations"
+		data["HistoryLink"] = "/exercise/" + id + "/history"
+		data["HeatmapLink"] = "/exercise/" + id + "/heatmap"
+		data["RecordsLink"] = "/exercise/" + id + "/records"
+		data["ProgressLink"] = "/exercise/" + id + "/progress"
 	}
-	a.ListExercises(c)
+	page := exerciseForm().SetData(data).Wrap(mainContent(), "Content")
+	a.render(c, &page)
 }
 
 func (a *App) ValidateExercise(c *gin.Context) {
@@ -373,10 +290,12 @@ func (a *App) ValidateExercise(c *gin.Context) {
 	}
 
 	err = c.ShouldBindWith(&exercise, binding.FormMultipart)
+	exercise.Aliases = splitAliases(exercise.Aliases)
 
 	switch {
 	case err != nil:
 		log.Printf("bind error: %v+", err)
+		err = fieldErrors(err, exercise)
 	case validationRequest:
 		err = errors.New("")
 	case id == "":
@@ -387,13 +306,29 @@ func (a *App) ValidateExercise(c *gin.Context) {
 
 	if err != nil {
 		data := map[string]any{
-			"PossibleValues": possibleValues,
+			"PossibleValues": a.possibleValues(),
 			"ValidationLink": template.HTMLAttr(validationLink),
-			"Input":          exercise,
 			"Error":          err.Error(),
 			"Button":         button,
 		}
-		page := htmx.NewComponent("templates/components/exercise_form.html").SetData(data).Wrap(mainContent(), "Content")
+		var fields FieldErrors
+		var conflict ConflictError
+		if errors.As(err, &fields) {
+			// shown next to the fields instead
+			data["Error"] = ""
+			data["Errors"] = fields
+		} else if errors.As(err, &conflict) {
+			// untouched images are kept, submitting again overwrites the
+			// current version
+			if len(exercise.Images) == 0 {
+				exercise.Images = conflict.Current.Images
+			}
+			exercise.Version = conflict.Current.Version
+			data["ID"] = id
+			data["Conflict"] = diffExercises(&conflict.Current, exercise)
+		}
+		data["Input"] = exercise
+		page := exerciseForm().SetData(data).Wrap(mainContent(), "Content")
 		a.render(c, &page)
 		return
 	}
@@ -405,48 +340,110 @@ func (a *App) updateExercise(c *gin.Context, exercise *Exercise, id string) erro
 	var err error
 	var fileNames []string
 
-	dbExercise, err := gorm.G[Exercise](a.db).Where("id = ?", id).First(*a.ctx)
+	dbExercise, err := gorm.G[Exercise](a.db).Preload("Parent", nil).Where("id = ?", id).First(*a.ctx)
 	if err != nil {
 		log.Printf("db error: %v+", err)
 		return err
 	}
+	if dbExercise.Version != exercise.Version {
+		return conflictError(dbExercise)
+	}
+
+	err = a.nameTaken(exercise, dbExercise.ID)
+	if err == nil {
+		err = a.setParent(exercise, dbExercise.ID)
+	}
+	if err != nil {
+		log.Printf("duplication error: %v+", err)
+		return err
+	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
 		return err
 	}
 	files := form.File["images"]
-
-	switch {
-	case len(files) > 0:
-		a.deleteImages(dbExercise.Images) // has to be before saveImages
-		fileNames, err = a.saveImages(exercise.Name, c, files)
-		if err != nil {
-			log.Printf("upload error: %v+", err)
-			return err
-		}
-	case len(dbExercise.Images) > 0:
+	if len(dbExercise.Images) > 0 {
 		fileNames = dbExercise.Images
 	}
 	exercise.Images = fileNames
 
-	_, err = gorm.G[Exercise](a.db).Where("id = ?", id).Updates(*a.ctx, *exercise)
+	version := exercise.Version
+	exercise.Version++
+	var saved []string
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		// select all columns, the form always contains every field and zero
+		// values like an empty parent have to be stored as well
+		rows, err := gorm.G[Exercise](tx).
+			Select("*").
+			Omit("id", "created_at", "trashed_at").
+			Where("id = ? AND version = ?", id, version).
+			Updates(*a.ctx, *exercise)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			// changed since the version check above
+			current, err := gorm.G[Exercise](tx).Preload("Parent", nil).Where("id = ?", id).First(*a.ctx)
+			if err != nil {
+				return err
+			}
+			return conflictError(current)
+		}
+		// the uploads are stored once the version matched, replaced images
+		// stay for reverting and new ones get a unique name
+		if len(files) > 0 {
+			fileNames, err := a.saveImages(dbExercise.ID, c, files)
+			if err != nil {
+				log.Printf("upload error: %v+", err)
+				return err
+			}
+			saved = fileNames
+			exercise.Images = fileNames
+			_, err = gorm.G[Exercise](tx).Select("images").Where("id = ?", id).Updates(*a.ctx, Exercise{Images: fileNames})
+			if err != nil {
+				return err
+			}
+		}
+		revision := *exercise
+		revision.ID = dbExercise.ID
+		return a.recordRevision(tx, currentUser(c), Updated, revision)
+	})
 	if err != nil {
 		log.Printf("db error: %v+", err)
+		// the uploads are not referenced after a rollback
+		a.deleteImages(saved)
+		// the form is shown again with the version it was based on
+		exercise.Version = version
 		return err
 	}
 
 	return nil
 }
 
+// ConflictError reports an edit based on an outdated version of the exercise.
+type ConflictError struct {
+	Current Exercise
+}
+
+func conflictError(current Exercise) ConflictError {
+	if current.Parent != nil {
+		current.ParentName = current.Parent.Name
+		current.Parent = nil
+	}
+	return ConflictError{current}
+}
+
+func (e ConflictError) Error() string {
+	return "exercise '" + e.Current.Name + "' was changed in the meantime, submit again to overwrite the changes"
+}
+
 func (a *App) insertExercise(c *gin.Context, exercise *Exercise) error {
-	count, err := gorm.G[Exercise](a.db).Where("name = ?", exercise.Name).Count(*a.ctx, "name")
-	if err != nil {
-		log.Printf("db error: %v+", err)
-		return err
+	err := a.nameTaken(exercise, 0)
+	if err == nil {
+		err = a.setParent(exercise, 0)
 	}
-	if count > 0 {
-		err = errors.New("exercise with name '" + exercise.Name + "' already exists")
+	if err != nil {
 		log.Printf("duplication error: %v+", err)
 		return err
 	}
@@ -456,23 +453,43 @@ func (a *App) insertExercise(c *gin.Context, exercise *Exercise) error {
 		return err
 	}
 	files := form.File["images"]
-	fileNames, err := a.saveImages(exercise.Name, c, files)
-	if err != nil {
-		log.Printf("upload error: %v+", err)
-		return err
-	}
-	exercise.Images = fileNames
+	exercise.Images = []string{}
 
-	err = gorm.G[Exercise](a.db).Create(*a.ctx, exercise)
+	var saved []string
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		if err := gorm.G[Exercise](tx).Create(*a.ctx, exercise); err != nil {
+			return err
+		}
+		// the images are named by the id of the new exercise
+		if len(files) > 0 {
+			fileNames, err := a.saveImages(exercise.ID, c, files)
+			if err != nil {
+				log.Printf("upload error: %v+", err)
+				return err
+			}
+			saved = fileNames
+			exercise.Images = fileNames
+			_, err = gorm.G[Exercise](tx).Select("images").Where("id = ?", exercise.ID).Updates(*a.ctx, Exercise{Images: fileNames})
+			if err != nil {
+				return err
+			}
+		}
+		return a.recordRevision(tx, currentUser(c), Created, *exercise)
+	})
 	if err != nil {
 		log.Printf("db error: %v+", err)
+		// the uploads are not referenced after a rollback
+		a.deleteImages(saved)
 		return err
 	}
 
 	return nil
 }
 
-func (a *App) saveImages(name string, c *gin.Context, files []*multipart.FileHeader) ([]string, error) {
+// saveImages stores the uploaded images of the exercise. Every upload gets
+// new names, the files of other uploads are still used by revisions. The
+// files stored before a failing one are removed again.
+func (a *App) saveImages(id uint, c *gin.Context, files []*multipart.FileHeader) ([]string, error) {
 	var saver func(*multipart.FileHeader, string, ...fs.FileMode) error
 	fileNames := []string{}
 	if a.mockFS != nil {
@@ -481,11 +498,16 @@ func (a *App) saveImages(name string, c *gin.Context, files []*multipart.FileHea
 		saver = c.SaveUploadedFile
 	}
 
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
 	for idx, file := range files {
-		fileName := name + "_" + strconv.Itoa(idx)
+		fileName := strconv.FormatUint(uint64(id), 10) + "_" + token + "_" + strconv.Itoa(idx)
 		log.Printf("saving file %s as ./static/images/%s", file.Filename, fileName)
 		err := saver(file, "./static/images/"+fileName)
 		if err != nil {
+			a.deleteImages(fileNames)
 			return nil, err
 		}
 		fileNames = append(fileNames, fileName)
@@ -529,14 +551,16 @@ func join(values any, sep string) string {
 	return b.String()
 }
 
-func exerciseAction(action string, id uint) any {
+// exerciseAction renders the button for an action in the exercise table, link
+// is the page the table is shown on.
+func exerciseAction(action string, id uint, link string) any {
 	switch action {
 	case "Del":
-		return template.HTML(`<button hx-delete="/exercise/` + strconv.FormatUint(uint64(id), 10) + `" hx-confirm="Delete exercise?">Del</button>`)
+		return template.HTML(`<button hx-delete="/exercise/` + strconv.FormatUint(uint64(id), 10) + `" hx-confirm="Move exercise to trash?" hx-target="#table" hx-swap="outerHTML" hx-include="#exercise-filter">Del</button>`)
 	case "Edit":
 		return template.HTML(`<button hx-get="/exercise/` + strconv.FormatUint(uint64(id), 10) + `" hx-push-url="/exercise/` + strconv.FormatUint(uint64(id), 10) + `">Edit</button>`)
 	case "Add":
-		return template.HTML(`<button hx-get="/exercise/` + strconv.FormatUint(uint64(id), 10) + `" hx-push-url="/exercise/` + strconv.FormatUint(uint64(id), 10) + `">Add</button>`)
+		return template.HTML(`<button hx-post="` + link + `/unit" hx-vals='{"exercise": "` + strconv.FormatUint(uint64(id), 10) + `"}' hx-target="#plan-units" hx-swap="outerHTML">Add</button>`)
 	default:
 		return ""
 	}
@@ -8,8 +8,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,11 +26,16 @@ var (
 		"Mechanic", "Category", "PrimaryMuscle", "SecondaryMuscles",
 		"Equipment", "Instructions", "Images"}
 	t1, _ = time.Parse("2025-10-11 15:04:09.152093+00", "2006-01-02 15:04:05.999999999Z07:00")
-	ex1   = []driver.Value{1, t1, t1, "fff", "0", "0", "0", "0", "0", "[5]",
-		"[8]", "asf", `["fff_0", "fff_1"]`}
+	ex1   = []driver.Value{1, t1, t1, "fff", "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Chest"]`,
+		`["Other"]`, "asf", `["fff_0", "fff_1"]`}
 	t2, _ = time.Parse("2025-10-11 15:08:09.152093+00", "2006-01-02 15:04:05.999999999Z07:00")
-	ex2   = []driver.Value{2, t2, t2, "bla", "1", "1", "1", "1", "8", "[1, 5]",
-		"[2, 8]", "ddd", "[]"}
+	ex2   = []driver.Value{2, t2, t2, "bla", "Push", "Middle", "Isolation", "Strength", "Hamstrings", `["Abductors", "Chest"]`,
+		`["Bench", "Other"]`, "ddd", "[]"}
+	presetCols  = []string{"ID", "CreatedAt", "UpdatedAt", "Username", "Name", "Query"}
+	preset1     = []driver.Value{1, t1, t1, "", "Home dumbbell push", "equipment=Dumbbells&force=Push"}
+	profileCols = []string{"ID", "CreatedAt", "UpdatedAt", "Username", "Name",
+		"Equipment", "PlateIncrements", "DumbbellIncrements"}
+	profile1        = []driver.Value{2, t1, t1, "alice", "Home", `["Body", "Dumbbells"]`, "[]", "[2, 4]"}
 	validateFixture = func(t *testing.T, fixture string, w *httptest.ResponseRecorder) {
 		assert.Equal(t, http.StatusOK, w.Code)
 
@@ -70,29 +77,62 @@ func TestListExercises(t *testing.T) {
 	}{
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
 					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(presetCols))
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(profileCols))
 			},
 			"./fixtures/exercise/list_empty.html",
 		},
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(presetCols))
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(profileCols))
 			},
 			"./fixtures/exercise/list_single.html",
 		},
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...).AddRow(ex2...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(presetCols).AddRow(preset1...))
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(profileCols))
 			},
 			"./fixtures/exercise/list_multiple.html",
 		},
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
 					WillReturnError(fmt.Errorf("test list error"))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(presetCols))
+				mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+					WithArgs("").
+					WillReturnRows(sqlmock.NewRows(profileCols))
 			},
 			"./fixtures/exercise/list_empty.html",
 		},
@@ -151,22 +191,37 @@ func TestValidateExercise(t *testing.T) {
 			func(a *App) {},
 			map[string][]string{
 				"name":      {"test"},
-				"secondary": {"1", "2"},
+				"secondary": {"Abductors", "Adductors"},
 			},
 			"./fixtures/exercise/validate_with_secondary.html",
 			validateFixture,
 			false,
 		},
+		{
+			func(a *App) {},
+			map[string][]string{
+				"name":         {"test"},
+				"force":        {"99"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "99"},
+				"equipment":    {"Barbell"},
+				"instructions": {"test"},
+			},
+			"./fixtures/exercise/validate_enum_range.html",
+			validateFixture,
+			false,
+		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("test").
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"test"}`, `{"test"}`, 1).
 					WillReturnError(fmt.Errorf("test count error"))
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_count_error.html",
@@ -175,19 +230,20 @@ func TestValidateExercise(t *testing.T) {
 		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("test").
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"test"}`, `{"test"}`, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","name","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING "id"`).
-					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "test", 0, 0, 0, 0, 0, "[1,2]", "[1]", "test", "[]").
+				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Abductors","Adductors"]`, `["Barbell"]`, "test", "[]", nil).
 					WillReturnError(fmt.Errorf("test insert error"))
 				mocksql.ExpectRollback()
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_insert_error.html",
@@ -196,14 +252,15 @@ func TestValidateExercise(t *testing.T) {
 		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("bla").
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"bla"}`, `{"bla"}`, 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
 			},
 			map[string][]string{
 				"name":         {"bla"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_existing_name.html",
@@ -214,8 +271,9 @@ func TestValidateExercise(t *testing.T) {
 			func(a *App) {},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_validation_request.html",
@@ -224,18 +282,28 @@ func TestValidateExercise(t *testing.T) {
 		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("test").
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"test"}`, `{"test"}`, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Abductors","Adductors"]`, `["Barbell"]`, "test", "[]", nil).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mocksql.ExpectRollback()
 				mockFS := &mockFS{}
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_0").Return(nil)
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_1").Return(fmt.Errorf("save file error"))
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 0))).Return(nil)
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 1))).Return(fmt.Errorf("save file error"))
 				a.mockFS = mockFS
+				// the image stored before the failing one is removed again
+				mockRM := &mockRM{}
+				mockRM.On("Remove", mock.MatchedBy(uploadedImage(1, 0))).Return(nil).Once()
+				a.mockRM = mockRM
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 				"images":       {"img1", "img2"},
 			},
@@ -245,19 +313,21 @@ func TestValidateExercise(t *testing.T) {
 		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("test").
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"test"}`, `{"test"}`, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","name","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING "id"`).
-					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "test", 0, 0, 0, 0, 0, "[1,2]", "[1]", "test", "[]").
+				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Abductors","Adductors"]`, `["Barbell"]`, "test", "[]", nil).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				expectRevision(1, Created)
 				mocksql.ExpectCommit()
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./nonexistent/validate_valid.html",
@@ -272,23 +342,28 @@ func TestValidateExercise(t *testing.T) {
 		},
 		{
 			func(a *App) {
-				mocksql.ExpectQuery(`SELECT COUNT("name") FROM "exercises" WHERE name = $1`).
-					WithArgs("test").
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) LIMIT $3`).
+					WithArgs(`{"test"}`, `{"test"}`, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","name","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING "id"`).
-					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "test", 0, 0, 0, 0, 0, "[1,2]", "[1]", "test", `["test_0","test_1"]`).
+				mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Abductors","Adductors"]`, `["Barbell"]`, "test", "[]", nil).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"images"=$2 WHERE id = $3`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectRevision(1, Created)
 				mocksql.ExpectCommit()
 				mockFS := &mockFS{}
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_0").Return(nil)
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_1").Return(nil)
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 0))).Return(nil)
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 1))).Return(nil)
 				a.mockFS = mockFS
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"1", "2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Abductors", "Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 				"images":       {"img1", "img2"},
 			},
@@ -319,6 +394,7 @@ func TestValidateExercise(t *testing.T) {
 
 			tt.validate(t, tt.fixture, w)
 			app.mockFS.AssertExpectations(t)
+			app.mockRM.AssertExpectations(t)
 		})
 	}
 }
@@ -337,9 +413,10 @@ func TestValidateExerciseWithID(t *testing.T) {
 			func(a *App) {},
 			map[string][]string{
 				"name":         {"test"},
-				"force":        {"2"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"force":        {"Static"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_valid_with_id.html",
@@ -351,17 +428,22 @@ func TestValidateExerciseWithID(t *testing.T) {
 				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("42", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"name"=$2,"force"=$3,"secondary_muscles"=$4,"equipment"=$5,"instructions"=$6,"images"=$7 WHERE id = $8`).
-					WithArgs(sqlmock.AnyArg(), "test", 2, "[2]", "[1]", "test", `["fff_0","fff_1"]`, "42").
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Static", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectRevision(1, Updated)
 				mocksql.ExpectCommit()
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"force":        {"2"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"force":        {"Static"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./nonexistent/validate_valid_with_id_validation_only.html",
@@ -382,29 +464,81 @@ func TestValidateExerciseWithID(t *testing.T) {
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_with_id_db_error.html",
 			validateFixture,
 			false,
 		},
+		{
+			func(a *App) {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("42", 1).
+					WillReturnRows(sqlmock.NewRows(append(exCols, "Version")).AddRow(append(ex1, 2)...))
+			},
+			map[string][]string{
+				"name":         {"test"},
+				"force":        {"Push"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Chest"},
+				"equipment":    {"Other"},
+				"instructions": {"asf"},
+			},
+			"./fixtures/exercise/validate_with_id_conflict.html",
+			validateFixture,
+			false,
+		},
+		{
+			func(a *App) {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("42", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("42", 1).
+					WillReturnRows(sqlmock.NewRows(append(exCols, "Version")).AddRow(append(ex2, 1)...))
+				mocksql.ExpectRollback()
+			},
+			map[string][]string{
+				"name":         {"test"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
+				"instructions": {"test"},
+				"images":       {"img1"},
+			},
+			"./fixtures/exercise/validate_with_id_update_conflict.html",
+			validateFixture,
+			false,
+		},
 		{
 			func(a *App) {
 				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("42", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"name"=$2,"secondary_muscles"=$3,"equipment"=$4,"instructions"=$5,"images"=$6 WHERE id = $7`).
-					WithArgs(sqlmock.AnyArg(), "test", "[2]", "[1]", "test", `["fff_0","fff_1"]`, "42").
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
 					WillReturnError(fmt.Errorf("test update error"))
 				mocksql.ExpectRollback()
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 			},
 			"./fixtures/exercise/validate_with_id_db_update_error.html",
@@ -416,20 +550,24 @@ func TestValidateExerciseWithID(t *testing.T) {
 				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("42", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
-				mockRM := &mockRM{}
-				rm1 := mockRM.On("Remove", "./static/images/fff_0").Return(nil)
-				rm2 := mockRM.On("Remove", "./static/images/fff_1").Return(nil)
-				a.mockRM = mockRM
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				mockFS := &mockFS{}
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_0").
-					Return(fmt.Errorf("save file error")).
-					NotBefore(rm1, rm2)
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 0))).
+					Return(fmt.Errorf("save file error"))
 				a.mockFS = mockFS
+				mocksql.ExpectRollback()
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 				"images":       {"img1"},
 			},
@@ -442,24 +580,28 @@ func TestValidateExerciseWithID(t *testing.T) {
 				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("42", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
 				mocksql.ExpectBegin()
-				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"name"=$2,"force"=$3,"secondary_muscles"=$4,"equipment"=$5,"instructions"=$6,"images"=$7 WHERE id = $8`).
-					WithArgs(sqlmock.AnyArg(), "test", 2, "[2]", "[1]", "test", `["test_0"]`, "42").
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Static", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"images"=$2 WHERE id = $3`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "42").
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectRevision(1, Updated)
 				mocksql.ExpectCommit()
-				mockRM := &mockRM{}
-				rm1 := mockRM.On("Remove", "./static/images/fff_0").Return(nil)
-				rm2 := mockRM.On("Remove", "./static/images/fff_1").Return(nil)
-				a.mockRM = mockRM
 				mockFS := &mockFS{}
-				mockFS.On("SaveUploadedFile", mock.Anything, "./static/images/test_0").Return(nil).NotBefore(rm1, rm2)
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 0))).Return(nil)
 				a.mockFS = mockFS
 			},
 			map[string][]string{
 				"name":         {"test"},
-				"force":        {"2"},
-				"secondary":    {"2"},
-				"equipment":    {"1"},
+				"force":        {"Static"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
 				"instructions": {"test"},
 				"images":       {"img1"},
 			},
@@ -473,6 +615,51 @@ func TestValidateExerciseWithID(t *testing.T) {
 			},
 			false,
 		},
+		{
+			func(a *App) {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("42", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"test"}`, `{"test"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 1, "test", "[]", nil, "Static", "Easy", "Compound", "Endurance", "Abdominals", `["Adductors"]`, `["Barbell"]`, "test", `["fff_0","fff_1"]`, "42", 0).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"images"=$2 WHERE id = $3`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "42").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mocksql.ExpectQuery(`INSERT INTO "exercise_revisions" ("created_at","exercise_id","username","action","snapshot") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg(), string(Updated), sqlmock.AnyArg()).
+					WillReturnError(fmt.Errorf("connection lost"))
+				mocksql.ExpectRollback()
+				mockFS := &mockFS{}
+				mockFS.On("SaveUploadedFile", mock.Anything, mock.MatchedBy(uploadedImage(1, 0))).Return(nil)
+				a.mockFS = mockFS
+				// the update is rolled back, the stored image is not referenced
+				mockRM := &mockRM{}
+				mockRM.On("Remove", mock.MatchedBy(uploadedImage(1, 0))).Return(nil).Once()
+				a.mockRM = mockRM
+			},
+			map[string][]string{
+				"name":         {"test"},
+				"force":        {"Static"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Adductors"},
+				"equipment":    {"Barbell"},
+				"instructions": {"test"},
+				"images":       {"img1"},
+			},
+			"./fixtures/exercise/validate_with_id_revision_error.html",
+			func(t *testing.T, s string, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), "connection lost")
+				if err := mocksql.ExpectationsWereMet(); err != nil {
+					t.Fatalf("unfulfilled expectations: %v", err)
+				}
+			},
+			false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -490,6 +677,7 @@ func TestValidateExerciseWithID(t *testing.T) {
 
 			tt.validate(t, tt.fixture, w)
 			app.mockFS.AssertExpectations(t)
+			app.mockRM.AssertExpectations(t)
 		})
 	}
 }
@@ -534,48 +722,48 @@ func TestReadExercises(t *testing.T) {
 
 func TestDeleteExercises(t *testing.T) {
 	router, app := SetupTestApp()
+	app.mockNow = &trashedAt
 
 	tests := []struct {
-		dbmocks func(*App)
+		dbmocks func()
 		fixture string
 	}{
 		{
-			func(a *App) {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 AND trashed_at IS NULL ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("1", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
-				mocksql.ExpectBegin()
-				mocksql.ExpectExec(`DELETE FROM "exercises" WHERE id = $1`).
-					WithArgs("1").
+				mocksql.ExpectExec(`UPDATE "exercises" SET "trashed_at"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs(trashedAt, sqlmock.AnyArg(), 1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectRevision(1, Trashed)
 				mocksql.ExpectCommit()
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
-				mockRM := &mockRM{}
-				mockRM.On("Remove", "./static/images/fff_0").Return(nil)
-				mockRM.On("Remove", "./static/images/fff_1").Return(nil)
-				a.mockRM = mockRM
 			},
-			"./fixtures/exercise/list_other_single.html",
+			"./fixtures/exercise/delete.html",
 		},
 		{
-			func(a *App) {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 AND trashed_at IS NULL ORDER BY "exercises"."id" LIMIT $2`).
 					WithArgs("1", 1).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
-				mocksql.ExpectBegin()
-				mocksql.ExpectExec(`DELETE FROM "exercises" WHERE id = $1`).
-					WithArgs("1").
+				mocksql.ExpectExec(`UPDATE "exercises" SET "trashed_at"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
 					WillReturnError(fmt.Errorf("test delete error"))
 				mocksql.ExpectRollback()
-				mocksql.ExpectQuery(`SELECT * FROM "exercises" ORDER BY id`).
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...).AddRow(ex2...))
-				mockRM := &mockRM{}
-				mockRM.On("Remove", "./static/images/fff_0").Return(nil)
-				mockRM.On("Remove", "./static/images/fff_1").Return(nil)
-				a.mockRM = mockRM
 			},
-			"./fixtures/exercise/list_multiple.html",
+			"./fixtures/exercise/delete_error.html",
 		},
 	}
 
@@ -584,7 +772,9 @@ func TestDeleteExercises(t *testing.T) {
 		t.Run(testname, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest("DELETE", "/exercise/1", nil)
-			tt.dbmocks(app)
+			req.Header.Set("HX-Request", "true")
+			req.Header.Set("HX-Target", "table")
+			tt.dbmocks()
 			router.ServeHTTP(w, req)
 
 			validateFixture(t, tt.fixture, w)
@@ -595,6 +785,42 @@ func TestDeleteExercises(t *testing.T) {
 func TestListExercisesWithFilter(t *testing.T) {
 	router, app := SetupTestApp()
 
+	where := `WHERE ("exercises"."category" = $1
+			AND "exercises"."force" = $2
+			AND "exercises"."level" = $3
+			AND "exercises"."mechanic" = $4
+			AND "exercises"."primary_muscle" = $5)
+		AND NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(secondary_muscles) elem
+			WHERE elem NOT IN (SELECT unnest($6::text[]))
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+			WHERE elem NOT IN (SELECT unnest($7::text[]))
+		)
+		AND trashed_at IS NULL`
+	whereMultiple := `WHERE ("exercises"."category" = $1
+			AND "exercises"."force" IN ($2,$3,$4)
+			AND "exercises"."level" IN ($5,$6,$7)
+			AND "exercises"."mechanic" = $8
+			AND "exercises"."primary_muscle" = $9)
+		AND NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(secondary_muscles) elem
+			WHERE elem NOT IN (SELECT unnest($10::text[]))
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+			WHERE elem NOT IN (SELECT unnest($11::text[]))
+		)
+		AND trashed_at IS NULL`
+	search := `AND (to_tsvector('english', name || ' ' || COALESCE(aliases::text, '') || ' ' || instructions) @@ websearch_to_tsquery('english', $8)
+			OR word_similarity($9, name) > $10
+			OR name ILIKE '%' || $11 || '%'
+			OR aliases::text ILIKE '%' || $12 || '%')`
+	single := []driver.Value{"Endurance", "Pull", "Easy", "Compound", "Abdominals", `{"Abdominals"}`, `{"Bands"}`}
+	multiple := []driver.Value{"Endurance", "Pull", "Push", "Static", "Easy", "Middle", "Hard", "Compound", "Abdominals",
+		`{"Abdominals","Abductors","Adductors","Biceps"}`, `{"Bands"}`}
+
 	tests := []struct {
 		dbmocks  func()
 		fixture  string
@@ -603,117 +829,197 @@ func TestListExercisesWithFilter(t *testing.T) {
 	}{
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises"
-						WHERE name LIKE $1
-						AND ("exercises"."category" = $2
-							AND "exercises"."force" = $3
-							AND "exercises"."level" = $4
-							AND "exercises"."mechanic" = $5
-							AND "exercises"."primary_muscle" = $6)
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" ` + where + ` ` + search).
+					WithArgs(append(single, "bech", "bech", 0.3, "bech", "bech")...).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT *, ts_rank(to_tsvector('english', name || ' ' || COALESCE(aliases::text, '') || ' ' || instructions), websearch_to_tsquery('english', $1)) + word_similarity($2, name) AS rank
+						FROM "exercises"
+						WHERE ("exercises"."category" = $3
+							AND "exercises"."force" = $4
+							AND "exercises"."level" = $5
+							AND "exercises"."mechanic" = $6
+							AND "exercises"."primary_muscle" = $7)
 						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(secondary_muscles) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($7::int[]))
+							SELECT 1 FROM jsonb_array_elements_text(secondary_muscles) elem
+							WHERE elem NOT IN (SELECT unnest($8::text[]))
 						)
 						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(equipment) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($8::int[]))
+							SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+							WHERE elem NOT IN (SELECT unnest($9::text[]))
 						)
-						ORDER BY id`).
-					WithArgs("%a%", 0, 0, 0, 0, 0, "{0}", "{0}").
+						AND trashed_at IS NULL
+						AND (to_tsvector('english', name || ' ' || COALESCE(aliases::text, '') || ' ' || instructions) @@ websearch_to_tsquery('english', $10)
+							OR word_similarity($11, name) > $12
+							OR name ILIKE '%' || $13 || '%'
+							OR aliases::text ILIKE '%' || $14 || '%')
+						ORDER BY "rank" DESC,"id" LIMIT $15`).
+					WithArgs(append(append([]driver.Value{"bech", "bech"}, single...), "bech", "bech", 0.3, "bech", "bech", 25)...).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
 			},
 			"./fixtures/exercise/filter_single_value.html",
 			map[string][]string{
-				"name":      {"a"},
-				"force":     {"0"},
-				"level":     {"0"},
-				"mechanic":  {"0"},
-				"category":  {"0"},
-				"primary":   {"0"},
-				"secondary": {"0"},
-				"equipment": {"0"},
+				"search":    {"bech"},
+				"force":     {"Pull"},
+				"level":     {"Easy"},
+				"mechanic":  {"Compound"},
+				"category":  {"Endurance"},
+				"primary":   {"Abdominals"},
+				"secondary": {"Abdominals"},
+				"equipment": {"Bands"},
 			},
 			validateFixture,
 		},
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises"
-						WHERE name LIKE $1
-						AND ("exercises"."category" = $2
-							AND "exercises"."force" IN ($3,$4,$5)
-							AND "exercises"."level" IN ($6,$7,$8)
-							AND "exercises"."mechanic" = $9
-							AND "exercises"."primary_muscle" = $10)
-						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(secondary_muscles) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($11::int[]))
-						)
-						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(equipment) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($12::int[]))
-						)
-						ORDER BY id`).
-					WithArgs("%abc%", 0, 0, 1, 2, 0, 1, 2, 0, 0, "{0,1,2,3}", "{0}").
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" ` + whereMultiple).
+					WithArgs(multiple...).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" ` + whereMultiple + ` ORDER BY "id" LIMIT $12`).
+					WithArgs(append(multiple, 25)...).
 					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
 			},
 			"./fixtures/exercise/filter_multiple_values.html",
 			map[string][]string{
-				"name":      {"abc"},
-				"force":     {"0", "1", "2"},
-				"level":     {"0", "1", "2"},
-				"mechanic":  {"0"},
-				"category":  {"0"},
-				"primary":   {"0"},
-				"secondary": {"0", "1", "2", "3"},
-				"equipment": {"0"},
+				"search":    {" "},
+				"force":     {"Pull", "Push", "Static"},
+				"level":     {"Easy", "Middle", "Hard"},
+				"mechanic":  {"Compound"},
+				"category":  {"Endurance"},
+				"primary":   {"Abdominals"},
+				"secondary": {"Abdominals", "Abductors", "Adductors", "Biceps"},
+				"equipment": {"Bands"},
 			},
 			validateFixture,
 		},
 		{
 			func() {
-				mocksql.ExpectQuery(`SELECT * FROM "exercises"
-						WHERE name LIKE $1
-						AND ("exercises"."category" = $2
-							AND "exercises"."force" IN ($3,$4,$5)
-							AND "exercises"."level" IN ($6,$7,$8)
-							AND "exercises"."mechanic" = $9
-							AND "exercises"."primary_muscle" = $10)
-						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(secondary_muscles) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($11::int[]))
-						)
-						AND NOT EXISTS (
-							SELECT 1 FROM jsonb_array_elements(equipment) elem
-							WHERE (elem::int) NOT IN (SELECT unnest($12::int[]))
-						)
-						ORDER BY id`).
-					WithArgs("%abc%", 0, 0, 1, 2, 0, 1, 2, 0, 0, "{0,1,2,3}", "{0}").
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" ` + whereMultiple).
+					WithArgs(multiple...).
 					WillReturnError(fmt.Errorf("test filter error"))
 			},
 			"./fixtures/exercise/filter_db_error.html",
 			map[string][]string{
-				"name":      {"abc"},
-				"force":     {"0", "1", "2"},
-				"level":     {"0", "1", "2"},
-				"mechanic":  {"0"},
-				"category":  {"0"},
-				"primary":   {"0"},
-				"secondary": {"0", "1", "2", "3"},
-				"equipment": {"0"},
+				"force":     {"Pull", "Push", "Static"},
+				"level":     {"Easy", "Middle", "Hard"},
+				"mechanic":  {"Compound"},
+				"category":  {"Endurance"},
+				"primary":   {"Abdominals"},
+				"secondary": {"Abdominals", "Abductors", "Adductors", "Biceps"},
+				"equipment": {"Bands"},
+			},
+			validateFixture,
+		},
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" ` + where).
+					WithArgs(single...).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" ` + where + ` ORDER BY "name" DESC,"id" LIMIT $8 OFFSET $9`).
+					WithArgs(append(single, 10, 10)...).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...).AddRow(ex1...))
+			},
+			"./fixtures/exercise/filter_sorted_page.html",
+			map[string][]string{
+				"force":     {"Pull"},
+				"level":     {"Easy"},
+				"mechanic":  {"Compound"},
+				"category":  {"Endurance"},
+				"primary":   {"Abdominals"},
+				"secondary": {"Abdominals"},
+				"equipment": {"Bands"},
+				"page":      {"3"},
+				"size":      {"10"},
+				"sort":      {"Name"},
+				"dir":       {"desc"},
+			},
+			validateFixture,
+		},
+		{
+			func() {
+				modes := `WHERE EXISTS (
+						SELECT 1 FROM jsonb_array_elements_text(secondary_muscles) elem
+						WHERE elem IN (SELECT unnest($1::text[]))
+					)
+					AND equipment @> to_jsonb($2::text[])
+					AND trashed_at IS NULL`
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" `+modes).
+					WithArgs(`{"Triceps"}`, `{"Barbell","Bench"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" `+modes+` ORDER BY "id" LIMIT $3`).
+					WithArgs(`{"Triceps"}`, `{"Barbell","Bench"}`, 25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+			},
+			"./fixtures/exercise/filter_match_modes.html",
+			map[string][]string{
+				"secondary":      {"Triceps"},
+				"secondary_mode": {"Any of"},
+				"equipment":      {"Barbell", "Bench"},
+				"equipment_mode": {"All of"},
+			},
+			validateFixture,
+		},
+		{
+			func() {
+				none := `WHERE NOT EXISTS (
+						SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+						WHERE elem IN (SELECT unnest($1::text[]))
+					)
+					AND trashed_at IS NULL`
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" ` + none).
+					WithArgs(`{"Kettlebells"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" `+none+` ORDER BY "id" LIMIT $2`).
+					WithArgs(`{"Kettlebells"}`, 25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+			},
+			"./fixtures/exercise/filter_none_of.html",
+			map[string][]string{
+				"equipment":      {"Kettlebells"},
+				"equipment_mode": {"None of"},
 			},
 			validateFixture,
 		},
 		{
-			func() {},
-			"./nonexistent/filter_bind_error.html",
+			func() {
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+			},
+			"./fixtures/exercise/filter_mode_bind_error.html",
 			map[string][]string{
-				"name":      {"abc"},
-				"force":     {"0"},
-				"equipment": {"0"},
+				"equipment":      {"Kettlebells"},
+				"equipment_mode": {"any of"},
+			},
+			validateFixture,
+		},
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
+					WillReturnRows(sqlmock.NewRows(exCols))
+			},
+			"./fixtures/exercise/filter_any.html",
+			map[string][]string{},
+			validateFixture,
+		},
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE trashed_at IS NULL`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at IS NULL ORDER BY "id" LIMIT $1`).
+					WithArgs(25).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
 			},
-			func(t *testing.T, fixture string, w *httptest.ResponseRecorder) {
-				assert.Equal(t, http.StatusBadRequest, w.Code)
+			"./fixtures/exercise/filter_bind_error.html",
+			map[string][]string{
+				"search": {"abc"},
+				"force":  {"sideways"},
 			},
+			validateFixture,
 		},
 	}
 
@@ -721,9 +1027,9 @@ func TestListExercisesWithFilter(t *testing.T) {
 		testname := filepath.Base(tt.fixture)
 		t.Run(testname, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			body, writer := createForm(tt.form)
-			req, _ := http.NewRequest("POST", "/exercise/list", body)
-			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req, _ := http.NewRequest("GET", "/exercise/list?"+url.Values(tt.form).Encode(), nil)
+			req.Header.Set("HX-Request", "true")
+			req.Header.Set("HX-Target", "table")
 			tt.dbmocks()
 			router.ServeHTTP(w, req)
 
@@ -732,3 +1038,116 @@ func TestListExercisesWithFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestListExercisesFromURL(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/exercise/list?search=+&force=Push&equipment=Dumbbells&equipment=Kettlebells&size=10", nil)
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises"
+			WHERE "exercises"."force" = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+				WHERE elem NOT IN (SELECT unnest($2::text[]))
+			)
+			AND trashed_at IS NULL`).
+		WithArgs("Push", `{"Dumbbells","Kettlebells"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises"
+			WHERE "exercises"."force" = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM jsonb_array_elements_text(equipment) elem
+				WHERE elem NOT IN (SELECT unnest($2::text[]))
+			)
+			AND trashed_at IS NULL
+			ORDER BY "id" LIMIT $3`).
+		WithArgs("Push", `{"Dumbbells","Kettlebells"}`, 10).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(presetCols).AddRow(preset1...))
+	mocksql.ExpectQuery(`SELECT * FROM "equipment_profiles" WHERE username = $1 ORDER BY name`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(profileCols).AddRow(profile1...))
+	req.Header.Set("Remote-User", "alice")
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/exercise/filter_from_url.html", w)
+}
+
+func TestPresets(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		dbmocks func()
+		method  string
+		target  string
+		form    map[string][]string
+		fixture string
+	}{
+		{
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`INSERT INTO "exercise_presets" ("created_at","updated_at","username","name","query") VALUES ($1,$2,$3,$4,$5) ON CONFLICT ("username","name") DO UPDATE SET "updated_at"="excluded"."updated_at","query"="excluded"."query" RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", "Home dumbbell push", "equipment=Dumbbells&force=Push").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(presetCols).AddRow(preset1...))
+			},
+			"POST",
+			"/exercise/preset",
+			map[string][]string{
+				"preset":    {"Home dumbbell push"},
+				"force":     {"Push"},
+				"equipment": {"Dumbbells"},
+				"page":      {"2"},
+			},
+			"./fixtures/exercise/preset_save.html",
+		},
+		{
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(presetCols))
+			},
+			"POST",
+			"/exercise/preset",
+			map[string][]string{
+				"force": {"Push"},
+			},
+			"./fixtures/exercise/preset_save_without_name.html",
+		},
+		{
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`DELETE FROM "exercise_presets" WHERE id = $1 AND username = $2`).
+					WithArgs("1", "alice").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_presets" WHERE username = $1 ORDER BY name`).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows(presetCols))
+			},
+			"DELETE",
+			"/exercise/preset/1",
+			map[string][]string{},
+			"./fixtures/exercise/preset_delete.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.target, strings.NewReader(url.Values(tt.form).Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Remote-User", "alice")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
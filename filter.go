@@ -0,0 +1,251 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExerciseFilter narrows down the exercise table, an empty field matches any
+// value.
+type ExerciseFilter struct {
+	Pagination
+	Search          string      `form:"search"`
+	Force           []Force     `form:"force" binding:"dive,enum"`
+	Level           []Level     `form:"level" binding:"dive,enum"`
+	Mechanic        []Mechanic  `form:"mechanic" binding:"dive,enum"`
+	Category        []Category  `form:"category" binding:"dive,enum"`
+	PrimaryMuscle   []Muscle    `form:"primary" binding:"dive,enum"`
+	SecondaryMuscle []Muscle    `form:"secondary" binding:"dive,enum"`
+	SecondaryMode   MatchMode   `form:"secondary_mode,default=Only these" binding:"enum"`
+	Equipment       []Equipment `form:"equipment" binding:"dive,enum"`
+	EquipmentMode   MatchMode   `form:"equipment_mode,default=Only these" binding:"enum"`
+	// Location is an equipment profile, exercises needing equipment not
+	// available there are hidden
+	Location uint `form:"location"`
+
+	available []Equipment
+}
+
+// MatchMode decides how a list column like the secondary muscles has to
+// match the selected values.
+type MatchMode = Enum[matchModeNames]
+
+const (
+	SubsetOf MatchMode = "Only these"
+	AnyOf    MatchMode = "Any of"
+	AllOf    MatchMode = "All of"
+	NoneOf   MatchMode = "None of"
+)
+
+type matchModeNames struct{}
+
+func (matchModeNames) names() []string {
+	return []string{"Only these", "Any of", "All of", "None of"}
+}
+
+// matchCondition returns the sql condition of the mode for the jsonb array
+// column, it expects the selected values as a single text array argument.
+func matchCondition(mode MatchMode, column string) string {
+	switch mode {
+	case AnyOf:
+		return `EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(` + column + `) elem
+			WHERE elem IN (SELECT unnest(?::text[]))
+		)`
+	case AllOf:
+		return column + ` @> to_jsonb(?::text[])`
+	case NoneOf:
+		return `NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(` + column + `) elem
+			WHERE elem IN (SELECT unnest(?::text[]))
+		)`
+	default:
+		return `NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(` + column + `) elem
+			WHERE elem NOT IN (SELECT unnest(?::text[]))
+		)`
+	}
+}
+
+// ExercisePreset is a named filter saved per user.
+type ExercisePreset struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Username  string `gorm:"uniqueIndex:idx_exercise_presets_username_name"`
+	Name      string `form:"preset" binding:"required" gorm:"uniqueIndex:idx_exercise_presets_username_name"`
+	Query     string
+}
+
+// URL links to the preset relative to the current page, so presets work on
+// every page showing the exercise filter.
+func (p ExercisePreset) URL() template.URL {
+	return template.URL("?" + p.Query)
+}
+
+func (f ExerciseFilter) apply(db *gorm.DB, catalog *Catalog) gorm.ChainInterface[Exercise] {
+	conditions := map[string]any{}
+	if len(f.Force) > 0 {
+		conditions["force"] = f.Force
+	}
+	if len(f.Level) > 0 {
+		conditions["level"] = f.Level
+	}
+	if len(f.Mechanic) > 0 {
+		conditions["mechanic"] = f.Mechanic
+	}
+	if len(f.Category) > 0 {
+		conditions["category"] = f.Category
+	}
+	if len(f.PrimaryMuscle) > 0 {
+		conditions["primary_muscle"] = catalog.withChildren(f.PrimaryMuscle)
+	}
+
+	query := gorm.G[Exercise](db).Where(conditions)
+	if len(f.SecondaryMuscle) > 0 {
+		secondary := f.SecondaryMuscle
+		// all of a group means the group itself, not every muscle below it
+		if f.SecondaryMode != AllOf {
+			secondary = catalog.withChildren(secondary)
+		}
+		query = query.Where(matchCondition(f.SecondaryMode, "secondary_muscles"), pq.Array(secondary))
+	}
+	if len(f.Equipment) > 0 {
+		query = query.Where(matchCondition(f.EquipmentMode, "equipment"), pq.Array(f.Equipment))
+	}
+	if f.available != nil {
+		query = query.Where(matchCondition(SubsetOf, "equipment"), pq.Array(f.available))
+	}
+	query = query.Where("trashed_at IS NULL")
+	return searchExercises(db, query, f.Search)
+}
+
+// Query encodes the filter without the current page.
+func (f ExerciseFilter) Query() url.Values {
+	values := url.Values{}
+	if f.Search != "" {
+		values.Set("search", f.Search)
+	}
+	addValues(values, "force", f.Force)
+	addValues(values, "level", f.Level)
+	addValues(values, "mechanic", f.Mechanic)
+	addValues(values, "category", f.Category)
+	addValues(values, "primary", f.PrimaryMuscle)
+	addValues(values, "secondary", f.SecondaryMuscle)
+	if f.SecondaryMode != SubsetOf {
+		values.Set("secondary_mode", f.SecondaryMode.String())
+	}
+	addValues(values, "equipment", f.Equipment)
+	if f.EquipmentMode != SubsetOf {
+		values.Set("equipment_mode", f.EquipmentMode.String())
+	}
+	if f.Location != 0 {
+		values.Set("location", strconv.FormatUint(uint64(f.Location), 10))
+	}
+	if f.Sort != "" {
+		values.Set("sort", f.Sort)
+		values.Set("dir", f.Dir)
+	}
+	if f.Size != 0 {
+		values.Set("size", strconv.Itoa(f.Size))
+	}
+	return values
+}
+
+func addValues[T ~string](values url.Values, key string, items []T) {
+	for _, item := range items {
+		values.Add(key, string(item))
+	}
+}
+
+func (a *App) SavePreset(c *gin.Context) {
+	var filter ExerciseFilter
+	var preset ExercisePreset
+	err := c.ShouldBindWith(&filter, binding.Form)
+	if err == nil {
+		err = c.ShouldBindWith(&preset, binding.Form)
+	}
+	if err != nil {
+		log.Printf("bind error: %v", err)
+		a.renderPresets(c, err)
+		return
+	}
+
+	preset.Username = currentUser(c)
+	preset.Query = filter.Query().Encode()
+	// saving under an existing name replaces the preset
+	err = gorm.G[ExercisePreset](a.db, clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "query"}),
+	}).Create(*a.ctx, &preset)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderPresets(c, err)
+}
+
+func (a *App) DeletePreset(c *gin.Context) {
+	id := c.Param("id")
+	_, err := gorm.G[ExercisePreset](a.db).
+		Where("id = ? AND username = ?", id, currentUser(c)).
+		Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderPresets(c, err)
+}
+
+func (a *App) renderPresets(c *gin.Context, err error) {
+	data := map[string]any{
+		"Presets": a.listPresets(c),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	presets := htmx.NewComponent("templates/components/exercise_presets.html").SetData(data)
+	a.render(c, &presets)
+}
+
+func (a *App) listPresets(c *gin.Context) []ExercisePreset {
+	presets, err := gorm.G[ExercisePreset](a.db).
+		Where("username = ?", currentUser(c)).
+		Order("name").
+		Find(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	return presets
+}
+
+func exerciseFilter() htmx.RenderableComponent {
+	presets := htmx.NewComponent("templates/components/exercise_presets.html")
+	return htmx.NewComponent("templates/components/exercise_filter.html").
+		With(presets, "Presets").
+		AddTemplateFunction("has", has)
+}
+
+// has reports whether the slice values contains value.
+func has(values any, value any) bool {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice {
+		return false
+	}
+
+	for i := range v.Len() {
+		if v.Index(i).Interface() == value {
+			return true
+		}
+	}
+	return false
+}
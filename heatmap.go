@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Heatmap is a front and back body diagram with the muscles colored by
+// intensity, muscles without a region of their own or of their group are
+// listed as Unplaced.
+type Heatmap struct {
+	Title    string
+	Outline  []HeatmapShape
+	Shapes   []HeatmapShape
+	Unplaced []HeatmapShape
+}
+
+// HeatmapShape is a rounded rectangle of the diagram.
+type HeatmapShape struct {
+	X, Y, W, H, R float64
+	Fill          string
+	Title         string
+}
+
+// bodyRegion is the area of a muscle on the front or back figure, mirrored
+// regions are drawn on both sides of the body.
+type bodyRegion struct {
+	muscle     string
+	back       bool
+	x, y, w, h float64
+	mirror     bool
+}
+
+const (
+	figureWidth = 100
+	backOffset  = 110
+)
+
+var (
+	bodyRegions = []bodyRegion{
+		{"Neck", false, 45, 24, 10, 7, false},
+		{"Shoulders", false, 26, 32, 12, 12, true},
+		{"Chest", false, 38, 34, 11, 16, true},
+		{"Biceps", false, 22, 46, 9, 20, true},
+		{"Forearms", false, 18, 68, 9, 24, true},
+		{"Abdominals", false, 42, 52, 16, 30, false},
+		{"Abductors", false, 31, 84, 5, 16, true},
+		{"Quadriceps", false, 36, 88, 10, 40, true},
+		{"Adductors", false, 46.5, 90, 3, 24, true},
+		{"Traps", true, 38, 26, 24, 14, false},
+		{"Shoulders", true, 26, 32, 12, 12, true},
+		{"Lats", true, 36, 44, 12, 22, true},
+		{"Triceps", true, 22, 46, 9, 20, true},
+		{"Forearms", true, 18, 68, 9, 24, true},
+		{"LowerBack", true, 42, 66, 16, 14, false},
+		{"Abductors", true, 32, 80, 5, 12, true},
+		{"Glutes", true, 38, 82, 12, 14, true},
+		{"Hamstrings", true, 37, 98, 11, 30, true},
+		{"Calves", true, 38, 134, 10, 26, true},
+	}
+	// bodyOutline is drawn for both figures
+	bodyOutline = []HeatmapShape{
+		{X: 40, Y: 4, W: 20, H: 20, R: 10},
+		{X: 34, Y: 30, W: 32, H: 56, R: 6},
+		{X: 17, Y: 32, W: 15, H: 62, R: 6},
+		{X: 68, Y: 32, W: 15, H: 62, R: 6},
+		{X: 35, Y: 84, W: 14, H: 100, R: 6},
+		{X: 51, Y: 84, W: 14, H: 100, R: 6},
+	}
+)
+
+// muscleHeatmap colors the regions of the muscles by their value relative
+// to the highest one, label formats a value for the tooltips. Values of
+// muscles without a region are added to the region of their group in the
+// catalog.
+func muscleHeatmap(catalog *Catalog, title string, values map[Muscle]float64, label func(float64) string) Heatmap {
+	placed := map[string]float64{}
+	unplaced := map[string]float64{}
+	for muscle, value := range values {
+		name := string(muscle)
+		if !hasRegion(name) {
+			if parent := catalog.parentName(name); hasRegion(parent) {
+				name = parent
+			}
+		}
+		if hasRegion(name) {
+			placed[name] += value
+		} else {
+			unplaced[name] += value
+		}
+	}
+	highest := 0.0
+	for _, value := range placed {
+		highest = max(highest, value)
+	}
+	for _, value := range unplaced {
+		highest = max(highest, value)
+	}
+
+	heatmap := Heatmap{Title: title}
+	for _, offset := range []float64{0, backOffset} {
+		for _, shape := range bodyOutline {
+			shape.X += offset
+			heatmap.Outline = append(heatmap.Outline, shape)
+		}
+	}
+	for _, region := range bodyRegions {
+		value := placed[region.muscle]
+		shape := HeatmapShape{
+			X: region.x, Y: region.y, W: region.w, H: region.h, R: 2,
+			Fill:  heatColor(value, highest),
+			Title: region.muscle,
+		}
+		if value > 0 {
+			shape.Title += ": " + label(value)
+		}
+		if region.back {
+			shape.X += backOffset
+		}
+		heatmap.Shapes = append(heatmap.Shapes, shape)
+		if region.mirror {
+			shape.X += figureWidth - 2*region.x - region.w
+			heatmap.Shapes = append(heatmap.Shapes, shape)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(unplaced)) {
+		heatmap.Unplaced = append(heatmap.Unplaced, HeatmapShape{
+			Fill:  heatColor(unplaced[name], highest),
+			Title: name + ": " + label(unplaced[name]),
+		})
+	}
+	return heatmap
+}
+
+func hasRegion(muscle string) bool {
+	return slices.ContainsFunc(bodyRegions, func(r bodyRegion) bool { return r.muscle == muscle })
+}
+
+// heatColor shades from light to dark red with the value, muscles without a
+// value stay grey.
+func heatColor(value, highest float64) string {
+	if value <= 0 || highest <= 0 {
+		return "#eee"
+	}
+	return fmt.Sprintf("hsl(0, 75%%, %.0f%%)", 90-55*value/highest)
+}
+
+// ExerciseHeatmap shows the primary and secondary muscles of the exercise.
+func (a *App) ExerciseHeatmap(c *gin.Context) {
+	exercise, err := gorm.G[Exercise](a.db).Where("id = ?", c.Param("id")).First(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	values := map[Muscle]float64{}
+	for _, muscle := range exercise.SecondaryMuscles {
+		values[muscle] = defaultSecondaryCredit
+	}
+	if exercise.PrimaryMuscle != "" {
+		values[exercise.PrimaryMuscle] = 1
+	}
+	heatmap := muscleHeatmap(a.catalog, "Muscles", values, func(value float64) string {
+		if value >= 1 {
+			return "primary"
+		}
+		return "secondary"
+	})
+	a.renderHeatmap(c, heatmap, err)
+}
+
+// WeekHeatmap shows the hard sets per muscle logged since the start of the
+// week, secondary muscles are credited with the share set by the user.
+func (a *App) WeekHeatmap(c *gin.Context) {
+	credit, err := a.secondaryCredit(c)
+	var rows []setMuscles
+	if err == nil {
+		rows, err = a.weekSets(weekStart(a.now()))
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+
+	values := map[Muscle]float64{}
+	for muscle, volume := range muscleVolumes(rows, credit) {
+		values[muscle] = volume.Sets
+	}
+	heatmap := muscleHeatmap(a.catalog, "Sets this week", values, func(value float64) string {
+		return formatNumber(value) + " sets"
+	})
+	a.renderHeatmap(c, heatmap, err)
+}
+
+// weekStart returns the start of the monday of the week of t.
+func weekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	year, month, day := t.AddDate(0, 0, -days).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func (a *App) renderHeatmap(c *gin.Context, heatmap Heatmap, err error) {
+	data := map[string]any{
+		"Heatmap": heatmap,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	component := htmx.NewComponent("templates/components/muscle_heatmap.html").SetData(data)
+	a.render(c, &component)
+}
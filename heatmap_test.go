@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuscleHeatmap(t *testing.T) {
+	shoulders := uint(2)
+	catalog := &Catalog{}
+	catalog.set([]MuscleGroup{
+		{ID: 1, Name: "Chest"},
+		{ID: 3, Name: "Rear Delts", ParentID: &shoulders},
+		{ID: 4, Name: "Rotator Cuff"},
+		{ID: 2, Name: "Shoulders"},
+	}, nil)
+
+	heatmap := muscleHeatmap(catalog, "Sets", map[Muscle]float64{"Chest": 4, "Shoulders": 1, "Rear Delts": 1, "Rotator Cuff": 1},
+		func(value float64) string { return fmt.Sprint(value) })
+
+	chest := []HeatmapShape{}
+	for _, shape := range heatmap.Shapes {
+		if shape.Title == "Chest: 4" {
+			chest = append(chest, shape)
+		}
+	}
+	assert.Equal(t, []HeatmapShape{
+		{X: 38, Y: 34, W: 11, H: 16, R: 2, Fill: "hsl(0, 75%, 35%)", Title: "Chest: 4"},
+		{X: 51, Y: 34, W: 11, H: 16, R: 2, Fill: "hsl(0, 75%, 35%)", Title: "Chest: 4"},
+	}, chest)
+	assert.Contains(t, heatmap.Shapes,
+		HeatmapShape{X: 136, Y: 32, W: 12, H: 12, R: 2, Fill: "hsl(0, 75%, 62%)", Title: "Shoulders: 2"})
+	assert.Contains(t, heatmap.Shapes,
+		HeatmapShape{X: 45, Y: 24, W: 10, H: 7, R: 2, Fill: "#eee", Title: "Neck"})
+	assert.Equal(t, []HeatmapShape{{Fill: "hsl(0, 75%, 76%)", Title: "Rotator Cuff: 1"}}, heatmap.Unplaced)
+}
+
+func TestWeekStart(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	monday := time.Date(2025, 10, 6, 0, 0, 0, 0, berlin)
+	assert.Equal(t, monday, weekStart(time.Date(2025, 10, 6, 9, 0, 0, 0, berlin)))
+	assert.Equal(t, monday, weekStart(time.Date(2025, 10, 12, 23, 59, 0, 0, berlin)))
+	assert.Equal(t, monday.AddDate(0, 0, 7), weekStart(time.Date(2025, 10, 13, 0, 0, 0, 0, berlin)))
+}
+
+func TestHeatmaps(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		url     string
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"/exercise/2/heatmap",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("2", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+			},
+			"./fixtures/heatmap/exercise.html",
+		},
+		{
+			"/workout/heatmap",
+			func() {
+				expectVolumeSettings(sqlmock.NewRows(volumeSettingsCols))
+				expectWeekSets(sqlmock.NewRows(setMusclesCols).
+					AddRow("Chest", `["Shoulders", "Triceps"]`, 6, 2400.0).
+					AddRow("Triceps", `[]`, 3, 450.0))
+			},
+			"./fixtures/heatmap/week.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"io/fs"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"time"
 
 	"github.com/donseba/go-htmx"
 	"github.com/gin-gonic/gin"
@@ -40,6 +43,18 @@ type App struct {
 	ctx    *context.Context
 	mockFS *mockFS
 	mockRM *mockRM
+	// mockNow replaces the current time in tests
+	mockNow *time.Time
+	// catalog holds the muscles and equipment, see loadCatalog
+	catalog *Catalog
+}
+
+// now returns the current time.
+func (a *App) now() time.Time {
+	if a.mockNow != nil {
+		return *a.mockNow
+	}
+	return time.Now()
 }
 
 func main() {
@@ -50,22 +65,67 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = db.AutoMigrate(&Exercise{})
+	err = migrate(db)
 	if err != nil {
 		log.Fatal(err)
 	}
 	ctx := context.Background()
 	app := &App{
-		htmx: htmx.New(),
-		db:   db,
-		ctx:  &ctx,
+		htmx:    htmx.New(),
+		db:      db,
+		ctx:     &ctx,
+		catalog: newCatalog(defaultMuscles, defaultEquipment),
+	}
+	err = app.loadCatalog()
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	go app.purgeTrashEvery(time.Hour)
+
+	registerValidations(app.catalog)
+	err = app.seedPlanTemplates()
+	if err != nil {
+		log.Fatal(err)
+	}
 	router := app.setupRouter(gin.DebugMode)
 	err = router.Run(":8080")
 	log.Fatal(err)
 }
 
+func migrate(db *gorm.DB) error {
+	err := migrateEnums(db)
+	if err != nil {
+		return err
+	}
+	err = db.AutoMigrate(
+		&Exercise{}, &ExerciseRevision{}, &ExercisePreset{}, &EquipmentProfile{},
+		&MuscleGroup{}, &EquipmentType{},
+		&Plan{}, &Set{}, &Unit{}, &PlanVersion{}, &Seed{},
+		&Workout{}, &WorkoutUnit{}, &LoggedSet{},
+		&VolumeSettings{}, &VolumeTarget{},
+		&PersonalRecord{}, &RecordSettings{},
+		&Program{}, &ProgramDay{}, &ProgramWeek{},
+		&ScheduledWorkout{}, &CalendarFeed{},
+	)
+	if err != nil {
+		return err
+	}
+	err = seedCatalog(db)
+	if err != nil {
+		return err
+	}
+
+	// indexes for the exercise search, see searchExercises
+	return db.Exec(`
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+		CREATE INDEX IF NOT EXISTS idx_exercises_search ON exercises
+			USING gin (to_tsvector('english', name || ' ' || COALESCE(aliases::text, '') || ' ' || instructions));
+		CREATE INDEX IF NOT EXISTS idx_exercises_name_trgm ON exercises
+			USING gin (name gin_trgm_ops);
+	`).Error
+}
+
 func (a *App) setupRouter(mode string) *gin.Engine {
 	gin.SetMode(mode)
 
@@ -75,31 +135,107 @@ func (a *App) setupRouter(mode string) *gin.Engine {
 	router.GET("/", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/workout/list")
 	})
-	router.GET("/workout/list", a.ListWorkouts)
 	router.GET("/measurement/list", a.ListMeasurements)
 
+	workout := router.Group("/workout")
+	workout.GET("/list", a.ListWorkouts)
+	workout.GET("/heatmap", a.WeekHeatmap)
+	workout.GET("/volume", a.VolumeReport)
+	workout.POST("/volume/credit", a.SaveVolumeCredit)
+	workout.POST("/volume/target", a.SaveVolumeTarget)
+	workout.POST("", a.StartWorkout)
+	workout.GET("/:id", a.ReadWorkout)
+	workout.POST("/:id/finish", a.FinishWorkout)
+	workout.GET("/:id/set/:set/timer", a.WorkoutTimer)
+	workout.POST("/:id/unit/:unit/set", a.LogSet)
+	workout.GET("/:id/unit/:unit/substitutes", a.UnitSubstitutes)
+	workout.POST("/:id/unit/:unit/swap", a.SwapUnit)
+
 	ex := router.Group("/exercise")
 	ex.GET("/list", a.ListExercises)
-	ex.POST("/list", a.ListExercisesWithFilter)
+	ex.POST("/preset", a.SavePreset)
+	ex.DELETE("/preset/:id", a.DeletePreset)
 	ex.GET("", a.CreateExercise)
 	ex.POST("/validate", a.ValidateExercise)
+	ex.GET("/names", a.ExerciseNames)
+	ex.GET("/trash", a.ListTrash)
 	ex.GET("/:id", a.ReadExercise)
 	ex.DELETE("/:id", a.DeleteExercise)
+	ex.POST("/:id/restore", a.RestoreExercise)
+	ex.DELETE("/:id/purge", a.PurgeExercise)
 	ex.POST("/:id/validate", a.ValidateExercise)
+	ex.GET("/:id/substitutes", a.ExerciseSubstitutes)
+	ex.GET("/:id/variations", a.ExerciseVariations)
+	ex.GET("/:id/history", a.ExerciseHistory)
+	ex.GET("/:id/heatmap", a.ExerciseHeatmap)
+	ex.GET("/:id/progress", a.ExerciseProgress)
+	ex.GET("/:id/records", a.ListRecords)
+	ex.POST("/:id/records/formula", a.SaveFormula)
+	ex.POST("/:id/revert/:revision", a.RevertExercise)
+
+	cat := router.Group("/catalog")
+	cat.GET("", a.ListCatalog)
+	cat.POST("/muscle", a.SaveMuscle)
+	cat.POST("/muscle/:id", a.SaveMuscle)
+	cat.DELETE("/muscle/:id", a.DeleteMuscle)
+	cat.POST("/equipment", a.SaveEquipment)
+	cat.POST("/equipment/:id", a.SaveEquipment)
+	cat.DELETE("/equipment/:id", a.DeleteEquipment)
+
+	equipment := router.Group("/equipment")
+	equipment.GET("/list", a.ListProfiles)
+	equipment.POST("", a.SaveProfile)
+	equipment.DELETE("/:id", a.DeleteProfile)
 
 	plan := router.Group("/plan")
 	plan.GET("/list", a.ListPlans)
 	plan.GET("", a.CreatePlan)
+	plan.POST("/validate", a.ValidatePlan)
+	plan.POST("/import", a.ImportPlans)
+	plan.POST("/copy/:token", a.CopySharedPlan)
+	plan.GET("/:id", a.ReadPlan)
+	plan.POST("/:id", a.SavePlan)
+	plan.POST("/:id/clone", a.ClonePlan)
+	plan.GET("/:id/export", a.ExportPlan)
+	plan.GET("/:id/versions", a.PlanVersions)
+	plan.POST("/:id/share", a.SharePlan)
+	plan.DELETE("/:id/share", a.UnsharePlan)
+	plan.DELETE("/:id", a.DeletePlan)
+	plan.POST("/:id/unit", a.AddUnit)
+	plan.POST("/:id/unit/:unit", a.SaveUnit)
+	plan.DELETE("/:id/unit/:unit", a.DeleteUnit)
+	plan.POST("/:id/unit/:unit/join", a.JoinUnit)
+	plan.POST("/:id/unit/:unit/split", a.SplitUnit)
+	plan.POST("/:id/set/:set", a.SaveSet)
 
-	return router
-}
+	// shared plans are viewed with the link only and calendar apps fetch the
+	// feed with the token in its file name, the authenticating proxy has to
+	// let /shared and /calendar/feed/ through
+	shared := router.Group("/shared")
+	shared.GET("/:token", a.ReadSharedPlan)
+	shared.GET("/:token/export", a.ExportSharedPlan)
 
-func (a *App) ListWorkouts(c *gin.Context) {
-	data := map[string]any{
-		"Text": "Welcome to the workouts page",
-	}
-	page := htmx.NewComponent("templates/pages/workouts.html").SetData(data).Wrap(mainContent(), "Content")
-	a.render(c, &page)
+	program := router.Group("/program")
+	program.GET("/list", a.ListPrograms)
+	program.POST("", a.CreateProgram)
+	program.GET("/today", a.TodaysWorkouts)
+	program.GET("/:id", a.ReadProgram)
+	program.POST("/:id", a.SaveProgram)
+	program.DELETE("/:id", a.DeleteProgram)
+	program.POST("/:id/day", a.SaveProgramDay)
+	program.DELETE("/:id/day/:day", a.DeleteProgramDay)
+	program.POST("/:id/week/:week", a.SaveProgramWeek)
+	program.POST("/:id/start", a.StartProgram)
+
+	calendar := router.Group("/calendar")
+	calendar.GET("", a.Calendar)
+	calendar.POST("/schedule", a.ScheduleWorkout)
+	calendar.DELETE("/schedule/:id", a.UnscheduleWorkout)
+	calendar.POST("/feed", a.RenewCalendarFeed)
+	// public like /shared, see above
+	calendar.GET("/feed/:file", a.CalendarFeedICS)
+
+	return router
 }
 
 func (a *App) ListMeasurements(c *gin.Context) {
@@ -118,6 +254,29 @@ func (a *App) render(c *gin.Context, page *htmx.RenderableComponent) {
 	}
 }
 
+// currentUser returns the user name set by an authenticating reverse proxy.
+func currentUser(c *gin.Context) string {
+	return c.GetHeader("Remote-User")
+}
+
+// absoluteURL returns the link to the path for use outside of the app, like
+// in calendar apps or shared with others.
+func absoluteURL(c *gin.Context, path string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + path
+}
+
+// randomToken returns an unguessable token for links that work without
+// logging in.
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	return hex.EncodeToString(token), err
+}
+
 func mainContent() htmx.RenderableComponent {
 	data := map[string]any{
 		"MenuItems": []struct {
@@ -126,18 +285,13 @@ func mainContent() htmx.RenderableComponent {
 		}{
 			{"Workouts", "/workout/list"},
 			{"Plans", "/plan/list"},
+			{"Programs", "/program/list"},
+			{"Calendar", "/calendar"},
 			{"Measurements", "/measurement/list"},
 			{"Exercises", "/exercise/list"},
+			{"Equipment", "/equipment/list"},
 		},
 	}
 	navbar := htmx.NewComponent("templates/components/navbar.html")
 	return htmx.NewComponent("templates/index.html").SetData(data).With(navbar, "Navbar")
 }
-
-func allValues[T ~uint](count uint) []T {
-	out := make([]T, count)
-	for i := range out {
-		out[i] = T(i)
-	}
-	return out
-}
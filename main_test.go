@@ -24,12 +24,14 @@ func SetupTestApp() (*gin.Engine, *App) {
 	}), &gorm.Config{})
 	ctx := context.Background()
 	app := &App{
-		htmx:   htmx.New(),
-		db:     db,
-		ctx:    &ctx,
-		mockFS: &mockFS{},
-		mockRM: &mockRM{},
+		htmx:    htmx.New(),
+		db:      db,
+		ctx:     &ctx,
+		mockFS:  &mockFS{},
+		mockRM:  &mockRM{},
+		catalog: newCatalog(defaultMuscles, defaultEquipment),
 	}
+	registerValidations(app.catalog)
 	router := app.setupRouter(gin.TestMode)
 	return router, app
 }
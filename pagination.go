@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultPageSize = 25
+
+var pageSizes = []int{10, 25, 50, 100}
+
+type Pagination struct {
+	Page int    `form:"page"`
+	Size int    `form:"size"`
+	Sort string `form:"sort"`
+	Dir  string `form:"dir"`
+}
+
+type PageInfo struct {
+	Pagination
+	Total int64
+	Count int
+	Sizes []int
+}
+
+// normalize resets invalid values to the defaults so the pagination can be
+// used directly in queries. sortable maps column headers to db columns.
+func (p *Pagination) normalize(sortable map[string]string) {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if !slices.Contains(pageSizes, p.Size) {
+		p.Size = defaultPageSize
+	}
+	if _, ok := sortable[p.Sort]; !ok {
+		p.Sort = ""
+	}
+	if p.Dir != "desc" {
+		p.Dir = "asc"
+	}
+}
+
+func (p *Pagination) orderBy(sortable map[string]string) clause.OrderBy {
+	columns := []clause.OrderByColumn{}
+	if column, ok := sortable[p.Sort]; ok {
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Name: column},
+			Desc:   p.Dir == "desc",
+		})
+	}
+	// id is always the last order column to keep pages stable
+	columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	return clause.OrderBy{Columns: columns}
+}
+
+type pageQuery[T any] interface {
+	Count(ctx context.Context, column string) (int64, error)
+	Order(value any) gorm.ChainInterface[T]
+}
+
+// paginate counts all rows matching query and returns the requested page.
+// The page is clamped to the last page if it is out of range.
+func paginate[T any](ctx context.Context, query pageQuery[T], p Pagination, sortable map[string]string) ([]T, PageInfo, error) {
+	p.normalize(sortable)
+	info := PageInfo{Pagination: p, Sizes: pageSizes}
+
+	total, err := query.Count(ctx, "id")
+	if err != nil {
+		return nil, info, err
+	}
+	info.Total = total
+	if last := info.Pages(); info.Page > last {
+		info.Page = last
+	}
+
+	items, err := query.Order(info.orderBy(sortable)).
+		Limit(info.Size).
+		Offset((info.Page - 1) * info.Size).
+		Find(ctx)
+	info.Count = len(items)
+	return items, info, err
+}
+
+func (p PageInfo) Pages() int {
+	pages := int((p.Total + int64(p.Size) - 1) / int64(p.Size))
+	return max(pages, 1)
+}
+
+func (p PageInfo) From() int {
+	if p.Count == 0 {
+		return 0
+	}
+	return (p.Page-1)*p.Size + 1
+}
+
+func (p PageInfo) To() int {
+	return (p.Page-1)*p.Size + p.Count
+}
+
+func (p PageInfo) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p PageInfo) HasNext() bool {
+	return p.Page < p.Pages()
+}
+
+func (p PageInfo) Prev() int {
+	return max(p.Page-1, 1)
+}
+
+func (p PageInfo) Next() int {
+	return min(p.Page+1, p.Pages())
+}
+
+// NextDir returns the direction a click on the given column header should
+// sort by.
+func (p PageInfo) NextDir(sort string) string {
+	if p.Sort == sort && p.Dir == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginationNormalize(t *testing.T) {
+	sortable := map[string]string{"Name": "name"}
+
+	p := Pagination{Page: 0, Size: 37, Sort: "Secret", Dir: "up"}
+	p.normalize(sortable)
+	assert.Equal(t, Pagination{Page: 1, Size: defaultPageSize, Sort: "", Dir: "asc"}, p)
+
+	p = Pagination{Page: 2, Size: 50, Sort: "Name", Dir: "desc"}
+	p.normalize(sortable)
+	assert.Equal(t, Pagination{Page: 2, Size: 50, Sort: "Name", Dir: "desc"}, p)
+}
@@ -1,68 +1,416 @@
 package main
 
 import (
+	"errors"
+	"html/template"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/donseba/go-htmx"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 )
 
-type PlanFilter struct {
-	Name string `form:"name"`
-}
-
 type Plan struct {
-	ID        uint
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Sets      []Set
+	ID          uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `form:"name" binding:"required"`
+	Description string `form:"description" gorm:"type:text"`
+	// Template plans are listed in the template library for everyone, they
+	// are used through a copy
+	Template bool `form:"template"`
+	// ShareToken is the secret of the read-only link of a shared plan
+	ShareToken *string `form:"-" gorm:"uniqueIndex"`
+	Sets       []Set   `gorm:"constraint:OnDelete:CASCADE"`
 }
 
 type Set struct {
-	Units []Unit
+	ID         uint
+	PlanID     uint
+	Position   int
+	SetOptions `gorm:"embedded"`
+	Units      []Unit `gorm:"constraint:OnDelete:CASCADE"`
 }
 
 type Unit struct {
-	Exercise Exercise
-	Pause    time.Time
+	ID          uint
+	SetID       uint
+	Position    int
+	ExerciseID  uint
+	Exercise    Exercise
+	Pause       time.Duration
+	UnitTargets `gorm:"embedded;embeddedPrefix:target_"`
+}
+
+type UnitInput struct {
+	ExerciseID uint `form:"exercise" binding:"required"`
 }
 
 func (a *App) ListPlans(c *gin.Context) {
+	a.renderPlans(c, nil)
+}
+
+// renderPlans lists the plans and below them the templates.
+func (a *App) renderPlans(c *gin.Context, err error) {
+	all, dbErr := gorm.G[Plan](a.db).Order("name").Find(*a.ctx)
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+	plans, templates := []Plan{}, []Plan{}
+	for _, plan := range all {
+		if plan.Template {
+			templates = append(templates, plan)
+		} else {
+			plans = append(plans, plan)
+		}
+	}
+
 	data := map[string]any{
-		"Text": "Welcome to the plans page",
+		"Plans":     plans,
+		"Templates": templates,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
 	}
-	page := htmx.NewComponent("templates/pages/plans.html").SetData(data).Wrap(mainContent(), "Content")
+	page := htmx.NewComponent("templates/pages/plans.html").
+		SetData(data).
+		AddTemplateFunction("planAction", planAction).
+		Wrap(mainContent(), "Content")
 	a.render(c, &page)
 }
 
 func (a *App) CreatePlan(c *gin.Context) {
-	var exercises []Exercise
-	exercises, err := gorm.G[Exercise](a.db).Order("id").Find(*a.ctx)
+	data := map[string]any{
+		"Input": Plan{},
+	}
+	page := htmx.NewComponent("templates/components/plan_form.html").SetData(data).Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+func (a *App) ValidatePlan(c *gin.Context) {
+	var plan Plan
+	err := c.ShouldBindWith(&plan, binding.Form)
+	if err == nil {
+		err = gorm.G[Plan](a.db).Create(*a.ctx, &plan)
+	}
 	if err != nil {
-		log.Printf("db error: %v", err)
+		log.Printf("plan error: %v", err)
+		data := map[string]any{
+			"Input": plan,
+			"Error": err.Error(),
+		}
+		page := htmx.NewComponent("templates/components/plan_form.html").SetData(data).Wrap(mainContent(), "Content")
+		a.render(c, &page)
+		return
 	}
 
-	data := map[string]any{
-		"Input":     Plan{},
-		"Exercises": exercises,
-		"Columns": []string{
-			"Action", "Name", "Force", "Level", "Mechanic", "Category", "Primary", "Secondary", "Equipment", "Instructions", "Images",
-		},
-		"Actions":        []string{"Add"},
-		"PossibleValues": possibleValues,
-	}
-	plan := htmx.NewComponent("templates/components/plan_form.html")
-	table := htmx.NewComponent("templates/components/exercise_table.html").
-		AddTemplateFunction("exerciseAction", exerciseAction).
-		AddTemplateFunction("join", join)
-	filter := htmx.NewComponent("templates/components/exercise_filter.html")
-	page := htmx.NewComponent("templates/pages/create_plan.html").
-		With(plan, "Plan").
-		With(table, "Table").
-		With(filter, "Filter").
+	c.Header("HX-Location", `{"path":"/plan/`+strconv.FormatUint(uint64(plan.ID), 10)+`", "target":"#content"}`)
+}
+
+// ReadPlan shows the plan builder: the units of the plan and the exercise
+// table to add units from.
+func (a *App) ReadPlan(c *gin.Context) {
+	id := c.Param("id")
+	filter, bindErr := bindFilter(c)
+	data := a.filterExercises(c, filter, bindErr)
+	data["Actions"] = []string{"Add"}
+	data["ListLink"] = "/plan/" + id
+	if c.GetHeader("HX-Target") == "table" {
+		table := exerciseTable().SetData(data)
+		a.render(c, &table)
+		return
+	}
+
+	plan, err := a.loadPlan(id)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	data["Plan"] = plan
+	if plan.ShareToken != nil {
+		data["ShareLink"] = absoluteURL(c, "/shared/"+*plan.ShareToken)
+	}
+	data["Progressions"] = enumValues[progressionNames]()
+	data["SetTypes"] = enumValues[setTypeNames]()
+	data["TimerPresets"] = timerPresets
+	data["Presets"] = a.listPresets(c)
+	data["Profiles"] = a.listProfiles(c)
+	details := htmx.NewComponent("templates/components/plan_details.html")
+	units := htmx.NewComponent("templates/components/plan_units.html")
+	page := htmx.NewComponent("templates/pages/plan.html").
+		With(details, "Details").
+		With(units, "Units").
+		With(exerciseTable(), "Table").
+		With(exerciseFilter(), "Filter").
 		SetData(data).
 		Wrap(mainContent(), "Content")
 	a.render(c, &page)
 }
+
+// SavePlan sets the name and description of the plan and whether it is a
+// template.
+func (a *App) SavePlan(c *gin.Context) {
+	var input Plan
+	id := c.Param("id")
+	err := c.ShouldBindWith(&input, binding.Form)
+	if err == nil {
+		_, err = gorm.G[Plan](a.db).
+			Where("id = ?", id).
+			Select("name", "description", "template").
+			Updates(*a.ctx, input)
+	}
+	if err != nil {
+		log.Printf("plan error: %v", err)
+	}
+	a.renderDetails(c, id, err)
+}
+
+// SharePlan creates the read-only link of the plan, an existing link is
+// kept.
+func (a *App) SharePlan(c *gin.Context) {
+	id := c.Param("id")
+	token, err := randomToken()
+	if err == nil {
+		_, err = gorm.G[Plan](a.db).
+			Where("id = ? AND share_token IS NULL", id).
+			Update(*a.ctx, "share_token", token)
+	}
+	if err != nil {
+		log.Printf("share error: %v", err)
+	}
+	a.renderDetails(c, id, err)
+}
+
+// UnsharePlan removes the read-only link of the plan, it stops working.
+func (a *App) UnsharePlan(c *gin.Context) {
+	id := c.Param("id")
+	_, err := gorm.G[Plan](a.db).Where("id = ?", id).Update(*a.ctx, "share_token", nil)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderDetails(c, id, err)
+}
+
+func (a *App) renderDetails(c *gin.Context, id string, err error) {
+	plan, loadErr := gorm.G[Plan](a.db).Where("id = ?", id).First(*a.ctx)
+	if loadErr != nil {
+		log.Printf("db error: %v", loadErr)
+		err = errors.Join(err, loadErr)
+	}
+
+	data := map[string]any{
+		"Plan": plan,
+	}
+	if plan.ShareToken != nil {
+		data["ShareLink"] = absoluteURL(c, "/shared/"+*plan.ShareToken)
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	details := htmx.NewComponent("templates/components/plan_details.html").SetData(data)
+	a.render(c, &details)
+}
+
+// ClonePlan copies the plan with its sets and units and opens the copy.
+func (a *App) ClonePlan(c *gin.Context) {
+	plan, err := a.loadPlan(c.Param("id"))
+	err = a.clonePlan(c, plan, err)
+	if err != nil {
+		a.renderPlans(c, err)
+	}
+}
+
+// clonePlan stores a copy of the plan and opens it, the caller shows the
+// returned error.
+func (a *App) clonePlan(c *gin.Context, plan Plan, err error) error {
+	if err == nil {
+		plan = plan.clone()
+		err = gorm.G[Plan](a.db).Create(*a.ctx, &plan)
+	}
+	if err != nil {
+		log.Printf("clone error: %v", err)
+		return err
+	}
+
+	c.Header("HX-Location", `{"path":"/plan/`+strconv.FormatUint(uint64(plan.ID), 10)+`", "target":"#content"}`)
+	return nil
+}
+
+// clone returns a copy of the plan to create. The copy of a template keeps
+// its name but is a plan of its own.
+func (p Plan) clone() Plan {
+	clone := Plan{Name: p.Name, Description: p.Description}
+	if !p.Template {
+		clone.Name += " (copy)"
+	}
+	for _, set := range p.Sets {
+		units := []Unit{}
+		for _, unit := range set.Units {
+			units = append(units, Unit{
+				Position:    unit.Position,
+				ExerciseID:  unit.ExerciseID,
+				Pause:       unit.Pause,
+				UnitTargets: unit.UnitTargets,
+			})
+		}
+		clone.Sets = append(clone.Sets, Set{Position: set.Position, SetOptions: set.SetOptions, Units: units})
+	}
+	return clone
+}
+
+func (a *App) DeletePlan(c *gin.Context) {
+	id := c.Param("id")
+	_, err := gorm.G[Plan](a.db).Where("id = ?", id).Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderPlans(c, err)
+}
+
+// AddUnit appends the exercise as a new set to the end of the plan.
+func (a *App) AddUnit(c *gin.Context) {
+	var input UnitInput
+	id := c.Param("id")
+	planID, err := strconv.ParseUint(id, 10, 0)
+	if err == nil {
+		err = c.ShouldBindWith(&input, binding.Form)
+	}
+	if err == nil {
+		err = a.db.Transaction(func(tx *gorm.DB) error {
+			position, err := a.nextPosition(tx, "sets", "plan_id = ?", planID)
+			if err != nil {
+				return err
+			}
+			set := Set{
+				PlanID:     uint(planID),
+				Position:   position,
+				SetOptions: SetOptions{Type: StraightSet},
+				Units: []Unit{{
+					ExerciseID:  input.ExerciseID,
+					UnitTargets: UnitTargets{Progression: NoProgression},
+				}},
+			}
+			return gorm.G[Set](tx).Create(*a.ctx, &set)
+		})
+	}
+	if err != nil {
+		log.Printf("unit error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+// SaveUnit sets the targets and progression rule of a unit.
+func (a *App) SaveUnit(c *gin.Context) {
+	var targets UnitTargets
+	id := c.Param("id")
+	err := c.ShouldBindWith(&targets, binding.Form)
+	if err == nil {
+		err = targets.validate()
+	}
+	if err == nil {
+		_, err = gorm.G[Unit](a.db).
+			Where("id = ? AND set_id IN (SELECT id FROM sets WHERE plan_id = ?)", c.Param("unit"), id).
+			Select("target_sets", "target_reps", "target_weight", "target_progression", "target_increment",
+				"target_min_reps", "target_max_reps", "target_training_max", "target_percentage", "target_rpe").
+			Updates(*a.ctx, Unit{UnitTargets: targets})
+	}
+	if err != nil {
+		log.Printf("unit error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+func (a *App) DeleteUnit(c *gin.Context) {
+	id := c.Param("id")
+	_, err := gorm.G[Unit](a.db).
+		Where("id = ? AND set_id IN (SELECT id FROM sets WHERE plan_id = ?)", c.Param("unit"), id).
+		Delete(*a.ctx)
+	if err == nil {
+		err = a.deleteEmptySets(a.db, id)
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+// deleteEmptySets deletes the sets of the plan left without units.
+func (a *App) deleteEmptySets(db *gorm.DB, plan string) error {
+	_, err := gorm.G[Set](db).
+		Where("plan_id = ? AND NOT EXISTS (SELECT 1 FROM units WHERE units.set_id = sets.id)", plan).
+		Delete(*a.ctx)
+	return err
+}
+
+// nextPosition returns the position after the last row of the table matching
+// the condition, deleting rows leaves gaps so their count may be taken.
+func (a *App) nextPosition(tx *gorm.DB, table string, condition string, args ...any) (int, error) {
+	var position int
+	err := tx.WithContext(*a.ctx).
+		Table(table).
+		Select("COALESCE(MAX(position), -1) + 1").
+		Where(condition, args...).
+		Scan(&position).Error
+	return position, err
+}
+
+func (a *App) renderUnits(c *gin.Context, id string, err error) {
+	plan, loadErr := a.loadPlan(id)
+	if loadErr != nil {
+		log.Printf("db error: %v", loadErr)
+		err = errors.Join(err, loadErr)
+	}
+
+	data := map[string]any{
+		"Plan":         plan,
+		"Progressions": enumValues[progressionNames](),
+		"SetTypes":     enumValues[setTypeNames](),
+		"TimerPresets": timerPresets,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	units := htmx.NewComponent("templates/components/plan_units.html").SetData(data)
+	a.render(c, &units)
+}
+
+// loadPlan loads the plan with its sets and units in order.
+func (a *App) loadPlan(id any) (Plan, error) {
+	return a.loadPlanWhere("id = ?", id)
+}
+
+// loadPlanWhere loads the plan matching the condition like loadPlan.
+func (a *App) loadPlanWhere(query string, args ...any) (Plan, error) {
+	return gorm.G[Plan](a.db).
+		// plans saved before positions were taken after the last one may
+		// repeat a position, the id keeps their order and snapshots stable
+		Preload("Sets", func(db gorm.PreloadBuilder) error {
+			db.Order("position, id")
+			return nil
+		}).
+		Preload("Sets.Units", func(db gorm.PreloadBuilder) error {
+			db.Order("position, id")
+			return nil
+		}).
+		Preload("Sets.Units.Exercise", nil).
+		Where(query, args...).
+		First(*a.ctx)
+}
+
+func planAction(action string, id uint) any {
+	switch action {
+	case "Start":
+		return template.HTML(`<button hx-post="/workout?plan=` + strconv.FormatUint(uint64(id), 10) + `">Start</button>`)
+	case "Edit":
+		return template.HTML(`<button hx-get="/plan/` + strconv.FormatUint(uint64(id), 10) + `" hx-push-url="/plan/` + strconv.FormatUint(uint64(id), 10) + `">Edit</button>`)
+	case "Copy":
+		return template.HTML(`<button hx-post="/plan/` + strconv.FormatUint(uint64(id), 10) + `/clone">Copy</button>`)
+	case "Del":
+		return template.HTML(`<button hx-delete="/plan/` + strconv.FormatUint(uint64(id), 10) + `" hx-confirm="Delete plan?">Del</button>`)
+	default:
+		return ""
+	}
+}
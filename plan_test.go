@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	planCols = []string{"ID", "CreatedAt", "UpdatedAt", "Name"}
+	plan1    = []driver.Value{1, t1, t1, "Push day"}
+	setCols  = []string{"ID", "PlanID", "Position", "Type", "Rounds", "Rest", "Work"}
+	unitCols = []string{"ID", "SetID", "Position", "ExerciseID", "Pause",
+		"target_sets", "target_reps", "target_weight", "target_progression", "target_increment",
+		"target_min_reps", "target_max_reps", "target_training_max", "target_percentage", "target_rpe"}
+)
+
+func expectLoadPlan() {
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE id = $1 ORDER BY "plans"."id" LIMIT $2`).
+		WithArgs("1", 1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1 ORDER BY position, id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 0, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."set_id" = $1 ORDER BY position, id`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+}
+
+func TestAddUnit(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	form := url.Values{"exercise": {"2"}}
+	req, _ := http.NewRequest("POST", "/plan/1/unit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT COALESCE(MAX(position), -1) + 1 FROM "sets" WHERE plan_id = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(0))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(1, 0, "Straight", 0, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(4, 0, 2, 0, 0, 0, 0.0, NoProgression, 0.0, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mocksql.ExpectCommit()
+	expectLoadPlan()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/units.html", w)
+}
+
+func TestAddUnitAfterDelete(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	add := func(position int) {
+		w := httptest.NewRecorder()
+		form := url.Values{"exercise": {"2"}}
+		req, _ := http.NewRequest("POST", "/plan/1/unit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		mocksql.ExpectBegin()
+		mocksql.ExpectQuery(`SELECT COALESCE(MAX(position), -1) + 1 FROM "sets" WHERE plan_id = $1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(position))
+		mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+			WithArgs(1, position, "Straight", 0, 0, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4 + position))
+		mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+			WithArgs(4+position, 0, 2, 0, 0, 0, 0.0, NoProgression, 0.0, 0, 0, 0.0, 0.0, 0.0).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7 + position))
+		mocksql.ExpectCommit()
+		expectLoadPlan()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// the new set follows the one at 0, deleting the first leaves a single
+	// set at 1 and the next one goes after it instead of to the taken 1
+	add(1)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/plan/1/unit/7", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`DELETE FROM "units" WHERE id = $1 AND set_id IN (SELECT id FROM sets WHERE plan_id = $2)`).
+		WithArgs("7", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`DELETE FROM "sets" WHERE plan_id = $1 AND NOT EXISTS (SELECT 1 FROM units WHERE units.set_id = sets.id)`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	expectLoadPlan()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	add(2)
+
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+}
+
+func TestDeleteUnit(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/plan/1/unit/8", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`DELETE FROM "units" WHERE id = $1 AND set_id IN (SELECT id FROM sets WHERE plan_id = $2)`).
+		WithArgs("8", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`DELETE FROM "sets" WHERE plan_id = $1 AND NOT EXISTS (SELECT 1 FROM units WHERE units.set_id = sets.id)`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	expectLoadPlan()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/units.html", w)
+}
+
+func TestListPlans(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/plan/list", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "plans" ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "UpdatedAt", "Name", "Description", "Template"}).
+			AddRow(2, t1, t1, "5x5 A", "Five sets of five", true).
+			AddRow(1, t1, t1, "Push day", "", false))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/list.html", w)
+}
+
+func TestDeletePlanFailed(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/plan/1", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`DELETE FROM "plans" WHERE id = $1`).
+		WithArgs("1").
+		WillReturnError(errors.New("update or delete on table \"plans\" violates foreign key constraint \"fk_program_days_plan\""))
+	mocksql.ExpectRollback()
+	mocksql.ExpectQuery(`SELECT * FROM "plans" ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "UpdatedAt", "Name", "Description", "Template"}).
+			AddRow(1, t1, t1, "Push day", "", false))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/delete_failed.html", w)
+}
+
+func TestSavePlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	form := url.Values{"name": {"Push day"}, "description": {"Chest and triceps"}, "template": {"true"}}
+	req, _ := http.NewRequest("POST", "/plan/1", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`UPDATE "plans" SET "updated_at"=$1,"name"=$2,"description"=$3,"template"=$4 WHERE id = $5`).
+		WithArgs(sqlmock.AnyArg(), "Push day", "Chest and triceps", true, "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE id = $1 ORDER BY "plans"."id" LIMIT $2`).
+		WithArgs("1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "UpdatedAt", "Name", "Description", "Template"}).
+			AddRow(1, t1, t1, "Push day", "Chest and triceps", true))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/details.html", w)
+}
+
+func TestClonePlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/plan/1/clone", nil)
+	req.Header.Set("HX-Request", "true")
+	expectLoadPlan()
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Push day (copy)", "", false, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+		WithArgs(2, 0, "Straight", 0, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(5, 0, 2, 0, 3, 5, 60.0, LinearProgression, 2.5, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mocksql.ExpectCommit()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, `{"path":"/plan/2", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
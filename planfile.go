@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// PlanFile is a self-contained document of plans. Units reference exercises
+// by name and the exercises are defined along, on import they are matched by
+// name or alias and created if missing.
+type PlanFile struct {
+	Plans     []PlanFilePlan
+	Exercises []PlanFileExercise
+}
+
+// PlanFilePlan is a plan of a PlanFile.
+type PlanFilePlan struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Template    bool   `json:",omitempty"`
+	Sets        []PlanFileSet
+}
+
+// PlanFileSet is a set of a PlanFilePlan, the type defaults to a straight
+// set.
+type PlanFileSet struct {
+	SetOptions
+	Units []PlanFileUnit
+}
+
+// PlanFileUnit is a unit of a PlanFileSet, the progression defaults to none.
+type PlanFileUnit struct {
+	Exercise string
+	Pause    time.Duration `json:",omitempty"`
+	UnitTargets
+}
+
+// PlanFileExercise is the definition of an exercise used in a PlanFile.
+type PlanFileExercise struct {
+	Name             string
+	Aliases          []string `json:",omitempty"`
+	Force            Force
+	Level            Level
+	Mechanic         Mechanic
+	Category         Category
+	PrimaryMuscle    Muscle
+	SecondaryMuscles []Muscle
+	Equipment        []Equipment
+	Instructions     string
+}
+
+// planSeeds are the bundled plan files, replaced in tests.
+var planSeeds = "seeds/*.json"
+
+// importPlans creates the plans of the file, exercises created on the way
+// are recorded as created by the user.
+func (a *App) importPlans(tx *gorm.DB, file PlanFile, username string) ([]Plan, error) {
+	definitions := map[string]PlanFileExercise{}
+	for _, exercise := range file.Exercises {
+		definitions[strings.ToLower(exercise.Name)] = exercise
+	}
+	ids := map[string]uint{}
+	plans := []Plan{}
+	for _, filePlan := range file.Plans {
+		if filePlan.Name == "" {
+			return nil, errors.New("a plan has no name")
+		}
+		plan := Plan{Name: filePlan.Name, Description: filePlan.Description, Template: filePlan.Template}
+		for position, fileSet := range filePlan.Sets {
+			set := Set{Position: position, SetOptions: fileSet.SetOptions}
+			if set.Type == "" {
+				set.Type = StraightSet
+			}
+			if err := binding.Validator.ValidateStruct(&set.SetOptions); err != nil {
+				return nil, fmt.Errorf("%s: %w", plan.Name, fieldErrors(err, set.SetOptions))
+			}
+			if err := set.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", plan.Name, err)
+			}
+			for unitPosition, fileUnit := range fileSet.Units {
+				unit := Unit{
+					Position:    unitPosition,
+					Pause:       fileUnit.Pause,
+					UnitTargets: fileUnit.UnitTargets,
+				}
+				if unit.Progression == "" {
+					unit.Progression = NoProgression
+				}
+				if err := binding.Validator.ValidateStruct(&unit.UnitTargets); err != nil {
+					return nil, fmt.Errorf("%s: %w", plan.Name, fieldErrors(err, unit.UnitTargets))
+				}
+				if err := unit.validate(); err != nil {
+					return nil, fmt.Errorf("%s: %w", plan.Name, err)
+				}
+				name := strings.ToLower(fileUnit.Exercise)
+				id, ok := ids[name]
+				if !ok {
+					exercise, err := a.matchExercise(tx, fileUnit.Exercise, definitions[name], username)
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", plan.Name, err)
+					}
+					id, ids[name] = exercise.ID, exercise.ID
+				}
+				unit.ExerciseID = id
+				set.Units = append(set.Units, unit)
+			}
+			plan.Sets = append(plan.Sets, set)
+		}
+		if err := gorm.G[Plan](tx).Create(*a.ctx, &plan); err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// matchExercise returns the exercise with the name or one of the aliases of
+// the definition, or creates it from the definition.
+func (a *App) matchExercise(tx *gorm.DB, name string, definition PlanFileExercise, username string) (Exercise, error) {
+	names := []string{strings.ToLower(name)}
+	for _, alias := range definition.Aliases {
+		names = append(names, strings.ToLower(alias))
+	}
+	exercise, err := gorm.G[Exercise](tx).
+		Where(nameCondition, pq.Array(names), pq.Array(names)).
+		Order("trashed_at DESC NULLS FIRST, id").
+		First(*a.ctx)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return exercise, err
+	}
+	if definition.Name == "" {
+		return exercise, fmt.Errorf("exercise '%s' is not defined", name)
+	}
+
+	exercise = Exercise{
+		Name:             definition.Name,
+		Aliases:          definition.Aliases,
+		Force:            definition.Force,
+		Level:            definition.Level,
+		Mechanic:         definition.Mechanic,
+		Category:         definition.Category,
+		PrimaryMuscle:    definition.PrimaryMuscle,
+		SecondaryMuscles: definition.SecondaryMuscles,
+		Equipment:        definition.Equipment,
+		Instructions:     definition.Instructions,
+		Images:           []string{},
+	}
+	err = binding.Validator.ValidateStruct(&exercise)
+	if err != nil {
+		return exercise, fmt.Errorf("exercise '%s': %w", name, fieldErrors(err, exercise))
+	}
+	err = gorm.G[Exercise](tx).Create(*a.ctx, &exercise)
+	if err != nil {
+		return exercise, err
+	}
+	return exercise, a.recordRevision(tx, username, Created, exercise)
+}
+
+// planFile returns the plans as a plan file with the definitions of their
+// exercises, the plans are not exported as templates.
+func planFile(plans ...Plan) PlanFile {
+	file := PlanFile{Plans: []PlanFilePlan{}, Exercises: []PlanFileExercise{}}
+	defined := map[uint]bool{}
+	for _, plan := range plans {
+		filePlan := PlanFilePlan{Name: plan.Name, Description: plan.Description, Sets: []PlanFileSet{}}
+		for _, set := range plan.Sets {
+			fileSet := PlanFileSet{SetOptions: set.SetOptions, Units: []PlanFileUnit{}}
+			for _, unit := range set.Units {
+				fileSet.Units = append(fileSet.Units, PlanFileUnit{
+					Exercise:    unit.Exercise.Name,
+					Pause:       unit.Pause,
+					UnitTargets: unit.UnitTargets,
+				})
+				if defined[unit.ExerciseID] {
+					continue
+				}
+				defined[unit.ExerciseID] = true
+				file.Exercises = append(file.Exercises, PlanFileExercise{
+					Name:             unit.Exercise.Name,
+					Aliases:          unit.Exercise.Aliases,
+					Force:            unit.Exercise.Force,
+					Level:            unit.Exercise.Level,
+					Mechanic:         unit.Exercise.Mechanic,
+					Category:         unit.Exercise.Category,
+					PrimaryMuscle:    unit.Exercise.PrimaryMuscle,
+					SecondaryMuscles: unit.Exercise.SecondaryMuscles,
+					Equipment:        unit.Exercise.Equipment,
+					Instructions:     unit.Exercise.Instructions,
+				})
+			}
+			filePlan.Sets = append(filePlan.Sets, fileSet)
+		}
+		file.Plans = append(file.Plans, filePlan)
+	}
+	return file
+}
+
+// ExportPlan downloads the plan as a plan file.
+func (a *App) ExportPlan(c *gin.Context) {
+	plan, err := a.loadPlan(c.Param("id"))
+	a.exportPlan(c, plan, err)
+}
+
+func (a *App) exportPlan(c *gin.Context, plan Plan, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": plan.Name + ".json"}))
+	c.IndentedJSON(http.StatusOK, planFile(plan))
+}
+
+// ImportPlans creates the plans of an uploaded plan file and opens the first
+// one.
+func (a *App) ImportPlans(c *gin.Context) {
+	var file PlanFile
+	var plans []Plan
+	upload, err := c.FormFile("file")
+	if err == nil {
+		file, err = readUploadedPlanFile(upload)
+	}
+	if err == nil && len(file.Plans) == 0 {
+		err = errors.New("the file contains no plans")
+	}
+	if err == nil {
+		err = a.db.Transaction(func(tx *gorm.DB) error {
+			plans, err = a.importPlans(tx, file, currentUser(c))
+			return err
+		})
+	}
+	if err != nil {
+		log.Printf("import error: %v", err)
+		a.renderPlans(c, err)
+		return
+	}
+
+	c.Header("HX-Location", `{"path":"/plan/`+strconv.FormatUint(uint64(plans[0].ID), 10)+`", "target":"#content"}`)
+}
+
+// readUploadedPlanFile parses an uploaded plan file.
+func readUploadedPlanFile(upload *multipart.FileHeader) (PlanFile, error) {
+	var file PlanFile
+	data, err := upload.Open()
+	if err != nil {
+		return file, err
+	}
+	defer data.Close()
+	err = json.NewDecoder(data).Decode(&file)
+	if err != nil {
+		return file, fmt.Errorf("%s is not a plan file: %w", upload.Filename, err)
+	}
+	return file, nil
+}
+
+// Seed marks bundled data as imported, it is not imported again once the
+// user deleted it.
+type Seed struct {
+	Name      string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// seedPlanTemplates imports the bundled templates into a database without
+// the seed marker, a new install as well as one from before the templates.
+// Once marked they are not imported again.
+func (a *App) seedPlanTemplates() error {
+	_, err := gorm.G[Seed](a.db).Where("name = ?", planSeeds).First(*a.ctx)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	names, err := filepath.Glob(planSeeds)
+	if err != nil {
+		return err
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			file, err := readPlanFile(name)
+			if err == nil {
+				_, err = a.importPlans(tx, file, "")
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return gorm.G[Seed](tx).Create(*a.ctx, &Seed{Name: planSeeds})
+	})
+}
+
+// readPlanFile reads a plan file from disk.
+func readPlanFile(name string) (PlanFile, error) {
+	var file PlanFile
+	data, err := os.ReadFile(name)
+	if err == nil {
+		err = json.Unmarshal(data, &file)
+	}
+	return file, err
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanSeeds(t *testing.T) {
+	names, err := filepath.Glob(planSeeds)
+	assert.NoError(t, err)
+	assert.Len(t, names, 3)
+	for _, name := range names {
+		file, err := readPlanFile(name)
+		assert.NoError(t, err, name)
+		defined := map[string]bool{}
+		for _, exercise := range file.Exercises {
+			defined[strings.ToLower(exercise.Name)] = true
+			assert.NotEmpty(t, exercise.Instructions, exercise.Name)
+		}
+		for _, plan := range file.Plans {
+			assert.True(t, plan.Template, plan.Name)
+			for _, set := range plan.Sets {
+				assert.NoError(t, set.validate(), plan.Name)
+				for _, unit := range set.Units {
+					assert.True(t, defined[strings.ToLower(unit.Exercise)], unit.Exercise)
+					assert.NoError(t, unit.validate(), unit.Exercise)
+				}
+			}
+		}
+	}
+}
+
+func TestImportPlans(t *testing.T) {
+	_, app := SetupTestApp()
+	file := PlanFile{
+		Plans: []PlanFilePlan{{
+			Name:     "Arms",
+			Template: true,
+			Sets: []PlanFileSet{{
+				SetOptions: SetOptions{Type: Superset},
+				Units: []PlanFileUnit{
+					{Exercise: "BLA", UnitTargets: UnitTargets{Sets: 3, Reps: 10}},
+					{Exercise: "Curl", UnitTargets: UnitTargets{Sets: 3, Reps: 12, Progression: LinearProgression, Increment: 1}},
+				},
+			}},
+		}},
+		Exercises: []PlanFileExercise{{
+			Name:             "Curl",
+			Aliases:          []string{"Biceps curl"},
+			Force:            Pull,
+			Level:            Easy,
+			Mechanic:         Isolation,
+			Category:         Strength,
+			PrimaryMuscle:    "Biceps",
+			SecondaryMuscles: []Muscle{"Forearms"},
+			Equipment:        []Equipment{"Dumbbells"},
+			Instructions:     "Curl the dumbbells up.",
+		}},
+	}
+
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) ORDER BY trashed_at DESC NULLS FIRST, id,"exercises"."id" LIMIT $3`).
+		WithArgs(`{"bla"}`, `{"bla"}`, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) ORDER BY trashed_at DESC NULLS FIRST, id,"exercises"."id" LIMIT $3`).
+		WithArgs(`{"curl","biceps curl"}`, `{"curl","biceps curl"}`, 1).
+		WillReturnRows(sqlmock.NewRows(exCols))
+	mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "Curl", `["Biceps curl"]`, nil, "Pull", "Easy", "Isolation", "Strength", "Biceps", `["Forearms"]`, `["Dumbbells"]`, "Curl the dumbbells up.", "[]", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mocksql.ExpectQuery(`INSERT INTO "exercise_revisions" ("created_at","exercise_id","username","action","snapshot") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 3, "alice", Created, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Arms", "", true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+		WithArgs(2, 0, "Superset", 0, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14),($15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(5, 0, 2, 0, 3, 10, 0.0, NoProgression, 0.0, 0, 0, 0.0, 0.0, 0.0,
+			5, 1, 3, 0, 3, 12, 0.0, LinearProgression, 1.0, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7).AddRow(8))
+	mocksql.ExpectCommit()
+
+	tx := app.db.Begin()
+	plans, err := app.importPlans(tx, file, "alice")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+	assert.Len(t, plans, 1)
+	assert.Equal(t, uint(2), plans[0].ID)
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) ORDER BY trashed_at DESC NULLS FIRST, id,"exercises"."id" LIMIT $3`).
+		WithArgs(`{"lunge"}`, `{"lunge"}`, 1).
+		WillReturnRows(sqlmock.NewRows(exCols))
+	_, err = app.importPlans(app.db, PlanFile{Plans: []PlanFilePlan{{
+		Name: "Legs",
+		Sets: []PlanFileSet{{Units: []PlanFileUnit{{Exercise: "Lunge"}}}},
+	}}}, "alice")
+	assert.EqualError(t, err, "Legs: exercise 'Lunge' is not defined")
+
+	// files are checked like the forms
+	_, err = app.importPlans(app.db, PlanFile{Plans: []PlanFilePlan{{
+		Name: "Legs",
+		Sets: []PlanFileSet{{SetOptions: SetOptions{Type: TimedSet, Rounds: 3, Rest: -10, Work: 30}}},
+	}}}, "alice")
+	assert.EqualError(t, err, "Legs: must be at least 0")
+	_, err = app.importPlans(app.db, PlanFile{Plans: []PlanFilePlan{{
+		Name: "Legs",
+		Sets: []PlanFileSet{{Units: []PlanFileUnit{{Exercise: "bla", UnitTargets: UnitTargets{Sets: 3, Reps: -5}}}}},
+	}}}, "alice")
+	assert.EqualError(t, err, "Legs: must be at least 0")
+}
+
+func TestExportPlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/plan/1/export", nil)
+	expectLoadPlan()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, `attachment; filename="Push day.json"`, w.Header().Get("Content-Disposition"))
+	validateFixture(t, "./fixtures/plan/export.json", w)
+
+	var file PlanFile
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &file))
+	assert.Equal(t, "bla", file.Plans[0].Sets[0].Units[0].Exercise)
+	assert.Equal(t, LinearProgression, file.Plans[0].Sets[0].Units[0].Progression)
+	assert.Equal(t, Muscle("Hamstrings"), file.Exercises[0].PrimaryMuscle)
+}
+
+func TestUploadPlans(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "plan.json")
+		part.Write([]byte(content))
+		writer.Close()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/plan/import", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("HX-Request", "true")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) ORDER BY trashed_at DESC NULLS FIRST, id,"exercises"."id" LIMIT $3`).
+		WithArgs(`{"bla"}`, `{"bla"}`, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Legs", "", false, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+		WithArgs(3, 0, "Straight", 0, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(5, 0, 2, 0, 3, 8, 0.0, NoProgression, 0.0, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mocksql.ExpectCommit()
+	w := upload(`{"Plans": [{"Name": "Legs", "Sets": [{"Units": [{"Exercise": "bla", "Sets": 3, "Reps": 8}]}]}]}`)
+	assert.Equal(t, `{"path":"/plan/3", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+
+	mocksql.ExpectQuery(`SELECT * FROM "plans" ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	w = upload(`{"Plans": [{"Name": "Legs", "Sets": [{"Type": "Tri set"}]}]}`)
+	validateFixture(t, "./fixtures/plan/import_invalid.html", w)
+}
+
+func TestSeedPlanTemplates(t *testing.T) {
+	_, app := SetupTestApp()
+	seeds := planSeeds
+	planSeeds = filepath.Join(t.TempDir(), "*.json")
+	t.Cleanup(func() { planSeeds = seeds })
+	seed := `{"Plans": [{"Name": "Legs", "Template": true, "Sets": [{"Units": [{"Exercise": "bla", "Sets": 3, "Reps": 8}]}]}]}`
+	assert.NoError(t, os.WriteFile(strings.Replace(planSeeds, "*", "legs", 1), []byte(seed), 0o644))
+
+	// not seeded yet, an existing database gets the templates too
+	mocksql.ExpectQuery(`SELECT * FROM "seeds" WHERE name = $1 ORDER BY "seeds"."name" LIMIT $2`).
+		WithArgs(planSeeds, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"Name", "CreatedAt"}))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) ) ORDER BY trashed_at DESC NULLS FIRST, id,"exercises"."id" LIMIT $3`).
+		WithArgs(`{"bla"}`, `{"bla"}`, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Legs", "", true, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+		WithArgs(3, 0, "Straight", 0, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(5, 0, 2, 0, 3, 8, 0.0, NoProgression, 0.0, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mocksql.ExpectExec(`INSERT INTO "seeds" ("name","created_at") VALUES ($1,$2)`).
+		WithArgs(planSeeds, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	assert.NoError(t, app.seedPlanTemplates())
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+
+	// seeded before, the plans were deleted since
+	mocksql.ExpectQuery(`SELECT * FROM "seeds" WHERE name = $1 ORDER BY "seeds"."name" LIMIT $2`).
+		WithArgs(planSeeds, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"Name", "CreatedAt"}).AddRow(planSeeds, t2))
+	assert.NoError(t, app.seedPlanTemplates())
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+}
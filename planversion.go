@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlanVersion is a plan as a workout was started with it. Versions are never
+// changed, a plan edited since its latest version gets a new one once it is
+// started again. Like revisions they have no foreign key to the plan, the
+// workouts of a deleted plan lose the plan but keep their version.
+type PlanVersion struct {
+	ID        uint
+	CreatedAt time.Time
+	PlanID    uint `gorm:"uniqueIndex:idx_plan_version"`
+	Number    int  `gorm:"uniqueIndex:idx_plan_version"`
+	Username  string
+	Snapshot  PlanSnapshot `gorm:"type:jsonb;serializer:json"`
+}
+
+// PlanSnapshot is a plan as versioned: the sets and units with their ids and
+// of the exercises just the name. Timestamps, the template flag and the
+// share link are not part of a version.
+type PlanSnapshot struct {
+	Name        string
+	Description string
+	Sets        []SetSnapshot
+}
+
+// SetSnapshot is a set of a PlanSnapshot.
+type SetSnapshot struct {
+	ID       uint
+	Position int
+	SetOptions
+	Units []UnitSnapshot
+}
+
+// UnitSnapshot is a unit of a SetSnapshot, the exercise is only named.
+type UnitSnapshot struct {
+	ID         uint
+	Position   int
+	ExerciseID uint
+	Exercise   string
+	Pause      time.Duration
+	UnitTargets
+}
+
+// VersionChanges is a plan version with the changes to the one before.
+type VersionChanges struct {
+	PlanVersion
+	Changes []FieldChange
+}
+
+// snapshot returns the plan as versioned.
+func (p Plan) snapshot() PlanSnapshot {
+	snapshot := PlanSnapshot{Name: p.Name, Description: p.Description, Sets: []SetSnapshot{}}
+	for _, set := range p.Sets {
+		units := []UnitSnapshot{}
+		for _, unit := range set.Units {
+			units = append(units, UnitSnapshot{
+				ID:          unit.ID,
+				Position:    unit.Position,
+				ExerciseID:  unit.ExerciseID,
+				Exercise:    unit.Exercise.Name,
+				Pause:       unit.Pause,
+				UnitTargets: unit.UnitTargets,
+			})
+		}
+		snapshot.Sets = append(snapshot.Sets, SetSnapshot{set.ID, set.Position, set.SetOptions, units})
+	}
+	return snapshot
+}
+
+// plan returns the plan of the version, the exercises have just their name.
+func (v PlanVersion) plan() Plan {
+	plan := Plan{ID: v.PlanID, Name: v.Snapshot.Name, Description: v.Snapshot.Description, Sets: []Set{}}
+	for _, set := range v.Snapshot.Sets {
+		units := []Unit{}
+		for _, unit := range set.Units {
+			units = append(units, Unit{
+				ID:          unit.ID,
+				SetID:       set.ID,
+				Position:    unit.Position,
+				ExerciseID:  unit.ExerciseID,
+				Exercise:    Exercise{ID: unit.ExerciseID, Name: unit.Exercise},
+				Pause:       unit.Pause,
+				UnitTargets: unit.UnitTargets,
+			})
+		}
+		plan.Sets = append(plan.Sets, Set{ID: set.ID, PlanID: v.PlanID, Position: set.Position, SetOptions: set.SetOptions, Units: units})
+	}
+	return plan
+}
+
+// recordPlanVersion returns the latest version of the plan, or records a new
+// one if the plan was changed since. tx is the transaction using the
+// version.
+func (a *App) recordPlanVersion(tx *gorm.DB, plan Plan, username string) (PlanVersion, error) {
+	snapshot := plan.snapshot()
+	latest, err := a.latestPlanVersion(tx, plan.ID)
+	if err == nil && sameSnapshot(latest.Snapshot, snapshot) {
+		return latest, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return latest, err
+	}
+
+	version := PlanVersion{
+		PlanID:   plan.ID,
+		Number:   latest.Number + 1,
+		Username: username,
+		Snapshot: snapshot,
+	}
+	err = gorm.G[PlanVersion](tx).Create(*a.ctx, &version)
+	return version, err
+}
+
+func (a *App) latestPlanVersion(db *gorm.DB, planID uint) (PlanVersion, error) {
+	return gorm.G[PlanVersion](db).
+		Where("plan_id = ?", planID).
+		Order("number DESC").
+		First(*a.ctx)
+}
+
+// sameSnapshot compares the snapshots as stored.
+func sameSnapshot(a, b PlanSnapshot) bool {
+	before, errA := json.Marshal(a)
+	after, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(before, after)
+}
+
+// planField is a field of a plan compared between versions.
+type planField struct {
+	Name  string
+	Value string
+}
+
+// planFields lists the fields of the plan in order, sets and units are
+// compared by their position.
+func planFields(p Plan) []planField {
+	fields := []planField{{"Name", p.Name}, {"Description", p.Description}}
+	for i, set := range p.Sets {
+		name := "Set " + strconv.Itoa(i+1)
+		value := set.Type.String()
+		if describe := set.Describe(); describe != "" {
+			value += ": " + describe
+		}
+		fields = append(fields, planField{name, value})
+		for j, unit := range set.Units {
+			fields = append(fields, planField{fmt.Sprintf("%s.%d", name, j+1), describeUnit(unit)})
+		}
+	}
+	return fields
+}
+
+// describeUnit summarizes the exercise and targets of a unit.
+func describeUnit(unit Unit) string {
+	value := unit.Exercise.Name
+	if unit.Reps > 0 {
+		value += fmt.Sprintf(" %d × %d", max(unit.Sets, 1), unit.Reps)
+		if unit.Weight > 0 {
+			value += " × " + formatNumber(unit.Weight)
+		}
+	}
+	if unit.Progression != NoProgression && unit.Progression != "" {
+		value += ", " + unit.Progression.String() + " progression"
+	}
+	if unit.Pause > 0 {
+		value += ", pause " + unit.Pause.String()
+	}
+	return value
+}
+
+// diffPlans lists the fields changed from old to new, without an old version
+// every field set in new is listed.
+func diffPlans(old *Plan, new Plan) []FieldChange {
+	before := map[string]string{}
+	var removed []planField
+	if old != nil {
+		removed = planFields(*old)
+		for _, field := range removed {
+			before[field.Name] = field.Value
+		}
+	}
+	changes := []FieldChange{}
+	after := map[string]bool{}
+	for _, field := range planFields(new) {
+		after[field.Name] = true
+		if before[field.Name] != field.Value {
+			changes = append(changes, FieldChange{field.Name, before[field.Name], field.Value})
+		}
+	}
+	for _, field := range removed {
+		if !after[field.Name] && field.Value != "" {
+			changes = append(changes, FieldChange{field.Name, field.Value, ""})
+		}
+	}
+	return changes
+}
+
+// PlanVersions shows the versions of the plan newest first, each compared to
+// the version before, and the changes not yet in a version.
+func (a *App) PlanVersions(c *gin.Context) {
+	id := c.Param("id")
+	versions, err := gorm.G[PlanVersion](a.db).
+		Where("plan_id = ?", id).
+		Order("number").
+		Find(*a.ctx)
+	var plan Plan
+	if err == nil {
+		plan, err = a.loadPlan(id)
+	}
+
+	data := map[string]any{
+		"ID": id,
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		data["Error"] = err.Error()
+	}
+	history := make([]VersionChanges, len(versions))
+	var previous *Plan
+	for i, version := range versions {
+		versioned := version.plan()
+		history[len(versions)-1-i] = VersionChanges{version, diffPlans(previous, versioned)}
+		previous = &versioned
+	}
+	data["Versions"] = history
+	if previous != nil && err == nil {
+		data["Pending"] = diffPlans(previous, plan)
+	}
+	component := htmx.NewComponent("templates/components/plan_versions.html").SetData(data)
+	a.render(c, &component)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var planVersionCols = []string{"ID", "CreatedAt", "PlanID", "Number", "Username", "Snapshot"}
+
+// versionedPlan is plan1 as loaded by expectLoadPlan.
+var versionedPlan = Plan{ID: 1, Name: "Push day", Sets: []Set{{
+	ID: 4, PlanID: 1, SetOptions: SetOptions{Type: StraightSet},
+	Units: []Unit{{
+		ID: 7, SetID: 4, ExerciseID: 2, Exercise: Exercise{ID: 2, Name: "bla"},
+		UnitTargets: UnitTargets{Sets: 3, Reps: 5, Weight: 60, Progression: LinearProgression, Increment: 2.5},
+	}},
+}}}
+
+// copyPlan copies the plan as versioned.
+func copyPlan(plan Plan) Plan {
+	return PlanVersion{PlanID: plan.ID, Snapshot: plan.snapshot()}.plan()
+}
+
+func planSnapshotJSON(plan Plan) string {
+	data, _ := json.Marshal(plan.snapshot())
+	return string(data)
+}
+
+// expectNewPlanVersion expects the first version of plan 1 to be recorded.
+func expectNewPlanVersion() {
+	mocksql.ExpectQuery(`SELECT * FROM "plan_versions" WHERE plan_id = $1 ORDER BY number DESC,"plan_versions"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(planVersionCols))
+	mocksql.ExpectQuery(`INSERT INTO "plan_versions" ("created_at","plan_id","number","username","snapshot") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 1, 1, "", planSnapshotJSON(versionedPlan)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+}
+
+func TestRecordPlanVersion(t *testing.T) {
+	_, app := SetupTestApp()
+
+	mocksql.ExpectQuery(`SELECT * FROM "plan_versions" WHERE plan_id = $1 ORDER BY number DESC,"plan_versions"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(planVersionCols).AddRow(3, t1, 1, 2, "alice", planSnapshotJSON(versionedPlan)))
+	version, err := app.recordPlanVersion(app.db, versionedPlan, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), version.ID)
+	assert.Equal(t, "alice", version.Username)
+
+	edited := copyPlan(versionedPlan)
+	edited.Sets[0].Units[0].Weight = 65
+	// timestamps and sharing are not versioned
+	edited.UpdatedAt = time.Now()
+	mocksql.ExpectQuery(`SELECT * FROM "plan_versions" WHERE plan_id = $1 ORDER BY number DESC,"plan_versions"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(planVersionCols).AddRow(3, t1, 1, 2, "alice", planSnapshotJSON(versionedPlan)))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`INSERT INTO "plan_versions" ("created_at","plan_id","number","username","snapshot") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 1, 3, "bob", planSnapshotJSON(edited)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mocksql.ExpectCommit()
+	version, err = app.recordPlanVersion(app.db, edited, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version.Number)
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDiffPlans(t *testing.T) {
+	edited := copyPlan(versionedPlan)
+	edited.Name = "Push"
+	edited.Sets[0].Units[0].Weight = 65
+	edited.Sets = append(edited.Sets, Set{
+		SetOptions: SetOptions{Type: Superset, Rest: 90},
+		Units:      []Unit{{Exercise: Exercise{Name: "dips"}}},
+	})
+	assert.Equal(t, []FieldChange{
+		{"Name", "Push day", "Push"},
+		{"Set 1.1", "bla 3 × 5 × 60, Linear progression", "bla 3 × 5 × 65, Linear progression"},
+		{"Set 2", "", "Superset: alternate the exercises, rest 1:30 min after each round"},
+		{"Set 2.1", "", "dips"},
+	}, diffPlans(&versionedPlan, edited))
+	assert.Equal(t, []FieldChange{
+		{"Name", "Push", "Push day"},
+		{"Set 1.1", "bla 3 × 5 × 65, Linear progression", "bla 3 × 5 × 60, Linear progression"},
+		{"Set 2", "Superset: alternate the exercises, rest 1:30 min after each round", ""},
+		{"Set 2.1", "dips", ""},
+	}, diffPlans(&edited, versionedPlan))
+	assert.Len(t, diffPlans(nil, versionedPlan), 3)
+}
+
+func TestWorkoutPlanVersion(t *testing.T) {
+	edited := versionedPlan
+	edited.Sets = []Set{{ID: 4, Units: []Unit{{ID: 7, SetID: 4, ExerciseID: 2, UnitTargets: UnitTargets{Reps: 8}}}}}
+	workout := Workout{
+		Intensity:   100,
+		Plan:        &edited,
+		PlanVersion: &PlanVersion{Number: 1, Snapshot: versionedPlan.snapshot()},
+		Units:       []WorkoutUnit{{UnitID: nil, Position: 0, ExerciseID: 2}},
+	}
+	// the unit was deleted from the plan since, the version keeps it
+	targets := workout.Targets(workout.Units[0])
+	assert.Equal(t, 5, targets.Reps)
+	assert.Equal(t, 60.0, targets.Weight)
+	groups := workout.Groups()
+	assert.Len(t, groups, 1)
+	assert.Equal(t, uint(4), groups[0].Set.ID)
+}
+
+func TestPlanVersions(t *testing.T) {
+	router, _ := SetupTestApp()
+	edited := copyPlan(versionedPlan)
+	edited.Sets[0].Units[0].Weight = 62.5
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/plan/1/versions", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "plan_versions" WHERE plan_id = $1 ORDER BY number`).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows(planVersionCols).
+			AddRow(2, time.Date(2025, 10, 13, 18, 0, 0, 0, time.UTC), 1, 1, "alice", planSnapshotJSON(versionedPlan)).
+			AddRow(3, time.Date(2025, 10, 15, 18, 0, 0, 0, time.UTC), 1, 2, "alice", planSnapshotJSON(edited)))
+	expectLoadPlan()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/plan/versions.html", w)
+}
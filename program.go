@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Program repeats its days every week for the number of weeks, starting
+// with the week of StartedOn.
+type Program struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string        `form:"name" binding:"required"`
+	Weeks     int           `form:"weeks" binding:"min=1,max=52"`
+	StartedOn *time.Time    `form:"-"`
+	Days      []ProgramDay  `gorm:"constraint:OnDelete:CASCADE"`
+	WeekPlans []ProgramWeek `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ProgramDay is the plan trained on a day of the week, 0 is monday.
+type ProgramDay struct {
+	ID        uint
+	ProgramID uint `gorm:"uniqueIndex:idx_program_days_program_weekday"`
+	Weekday   int  `form:"weekday" binding:"min=0,max=6" gorm:"uniqueIndex:idx_program_days_program_weekday"`
+	PlanID    uint `form:"plan" binding:"required"`
+	Plan      Plan `binding:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ProgramWeek modifies the weights of a week by the intensity in percent, a
+// deload week halves the sets and does not progress the targets. Weeks
+// without one train at full intensity.
+type ProgramWeek struct {
+	ID        uint
+	ProgramID uint    `gorm:"uniqueIndex:idx_program_weeks_program_week"`
+	Week      int     `gorm:"uniqueIndex:idx_program_weeks_program_week"`
+	Intensity float64 `form:"intensity" binding:"gt=0,lte=200"`
+	Deload    bool    `form:"deload"`
+}
+
+// ProgramStart is the date a program is started on as entered.
+type ProgramStart struct {
+	Date string `form:"date" binding:"required"`
+}
+
+// TodaysWorkout is the plan of a running program for today, Plan is empty
+// on rest days.
+type TodaysWorkout struct {
+	Program Program
+	Week    ProgramWeek
+	Plan    *Plan
+	Targets map[uint]UnitTargets
+}
+
+var weekdays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// weekday returns the day of the week of t, 0 is monday.
+func weekday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// week returns the week of the program t is in, counting from 1. It is
+// outside of 1 to Weeks before the start and after the end.
+func (p Program) week(t time.Time) int {
+	if p.StartedOn == nil {
+		return 0
+	}
+	weeks := weekStart(t).Sub(weekStart(*p.StartedOn)).Hours() / (24 * 7)
+	return int(math.Floor(weeks+0.5)) + 1
+}
+
+// weekPlan returns the modifiers of the week.
+func (p Program) weekPlan(week int) ProgramWeek {
+	idx := slices.IndexFunc(p.WeekPlans, func(w ProgramWeek) bool { return w.Week == week })
+	if idx < 0 {
+		return ProgramWeek{ProgramID: p.ID, Week: week, Intensity: 100}
+	}
+	return p.WeekPlans[idx]
+}
+
+// allWeeks lists the modifiers of every week of the program.
+func (p Program) allWeeks() []ProgramWeek {
+	weeks := make([]ProgramWeek, p.Weeks)
+	for i := range weeks {
+		weeks[i] = p.weekPlan(i + 1)
+	}
+	return weeks
+}
+
+// scaled returns the targets of a week, the weight is rounded to a quarter.
+func (t UnitTargets) scaled(week ProgramWeek) UnitTargets {
+	t.Weight = math.Round(t.Weight*week.Intensity/100*4) / 4
+	if week.Deload {
+		t.Sets = (t.Sets + 1) / 2
+	}
+	return t
+}
+
+func (a *App) ListPrograms(c *gin.Context) {
+	a.renderPrograms(c, Program{Weeks: 4}, nil)
+}
+
+func (a *App) CreateProgram(c *gin.Context) {
+	var program Program
+	err := c.ShouldBindWith(&program, binding.Form)
+	if err == nil {
+		err = gorm.G[Program](a.db).Create(*a.ctx, &program)
+	}
+	if err != nil {
+		log.Printf("program error: %v", err)
+		a.renderPrograms(c, program, err)
+		return
+	}
+	c.Header("HX-Location", `{"path":"/program/`+strconv.FormatUint(uint64(program.ID), 10)+`", "target":"#content"}`)
+}
+
+func (a *App) DeleteProgram(c *gin.Context) {
+	_, err := gorm.G[Program](a.db).Where("id = ?", c.Param("id")).Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderPrograms(c, Program{Weeks: 4}, err)
+}
+
+func (a *App) renderPrograms(c *gin.Context, input Program, err error) {
+	programs, dbErr := gorm.G[Program](a.db).Order("name").Find(*a.ctx)
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+	data := map[string]any{
+		"Programs": programs,
+		"Input":    input,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/programs.html").SetData(data).Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// ReadProgram shows the days and weeks of the program.
+func (a *App) ReadProgram(c *gin.Context) {
+	a.renderProgram(c, nil)
+}
+
+// SaveProgram renames the program or changes its length.
+func (a *App) SaveProgram(c *gin.Context) {
+	var program Program
+	err := c.ShouldBindWith(&program, binding.Form)
+	if err == nil {
+		_, err = gorm.G[Program](a.db).
+			Where("id = ?", c.Param("id")).
+			Select("name", "weeks").
+			Updates(*a.ctx, program)
+	}
+	if err != nil {
+		log.Printf("program error: %v", err)
+	}
+	a.renderProgram(c, err)
+}
+
+// SaveProgramDay sets the plan of a day of the week, replacing the one
+// planned before.
+func (a *App) SaveProgramDay(c *gin.Context) {
+	var day ProgramDay
+	programID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err == nil {
+		err = c.ShouldBindWith(&day, binding.Form)
+	}
+	if err == nil {
+		day.ProgramID = uint(programID)
+		err = gorm.G[ProgramDay](a.db, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "program_id"}, {Name: "weekday"}},
+			DoUpdates: clause.AssignmentColumns([]string{"plan_id"}),
+		}).Create(*a.ctx, &day)
+	}
+	if err != nil {
+		log.Printf("program day error: %v", err)
+	}
+	a.renderProgram(c, err)
+}
+
+// DeleteProgramDay makes the day a rest day.
+func (a *App) DeleteProgramDay(c *gin.Context) {
+	_, err := gorm.G[ProgramDay](a.db).
+		Where("id = ? AND program_id = ?", c.Param("day"), c.Param("id")).
+		Delete(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderProgram(c, err)
+}
+
+// SaveProgramWeek sets the intensity of a week and whether it is a deload.
+func (a *App) SaveProgramWeek(c *gin.Context) {
+	var week ProgramWeek
+	programID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err == nil {
+		week.Week, err = strconv.Atoi(c.Param("week"))
+	}
+	if err == nil {
+		err = c.ShouldBindWith(&week, binding.Form)
+	}
+	if err == nil {
+		week.ProgramID = uint(programID)
+		err = gorm.G[ProgramWeek](a.db, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "program_id"}, {Name: "week"}},
+			DoUpdates: clause.AssignmentColumns([]string{"intensity", "deload"}),
+		}).Create(*a.ctx, &week)
+	}
+	if err != nil {
+		log.Printf("program week error: %v", err)
+	}
+	a.renderProgram(c, err)
+}
+
+// StartProgram starts the program in the week of the date.
+func (a *App) StartProgram(c *gin.Context) {
+	var input ProgramStart
+	var date time.Time
+	err := c.ShouldBindWith(&input, binding.Form)
+	if err == nil {
+		date, err = time.ParseInLocation(time.DateOnly, input.Date, time.Local)
+	}
+	if err == nil {
+		_, err = gorm.G[Program](a.db).Where("id = ?", c.Param("id")).Update(*a.ctx, "started_on", weekStart(date))
+	}
+	if err != nil {
+		log.Printf("program start error: %v", err)
+	}
+	a.renderProgram(c, err)
+}
+
+func (a *App) renderProgram(c *gin.Context, err error) {
+	program, dbErr := a.loadProgram(c.Param("id"))
+	var plans []Plan
+	if dbErr == nil {
+		plans, dbErr = gorm.G[Plan](a.db).Order("name").Find(*a.ctx)
+	}
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+
+	data := map[string]any{
+		"Program":  program,
+		"Weeks":    program.allWeeks(),
+		"Current":  program.week(a.now()),
+		"Plans":    plans,
+		"Weekdays": weekdays,
+		"Today":    a.now().Format(time.DateOnly),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/program.html").
+		SetData(data).
+		AddTemplateFunction("weekday", func(day int) string { return weekdays[day] }).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// TodaysWorkouts shows for each running program the plan of today with the
+// targets of the week.
+func (a *App) TodaysWorkouts(c *gin.Context) {
+	now := a.now()
+	programs, err := gorm.G[Program](a.db).
+		Preload("Days", nil).
+		Preload("WeekPlans", nil).
+		Where("started_on IS NOT NULL").
+		Order("name").
+		Find(*a.ctx)
+	workouts := []TodaysWorkout{}
+	for _, program := range programs {
+		week := program.week(now)
+		if week < 1 || week > program.Weeks {
+			continue
+		}
+		today := TodaysWorkout{Program: program, Week: program.weekPlan(week)}
+		idx := slices.IndexFunc(program.Days, func(d ProgramDay) bool { return d.Weekday == weekday(now) })
+		if idx >= 0 {
+			var plan Plan
+			plan, err = a.loadPlan(program.Days[idx].PlanID)
+			if err != nil {
+				break
+			}
+			today.Plan = &plan
+			today.Targets = map[uint]UnitTargets{}
+			for _, set := range plan.Sets {
+				for _, unit := range set.Units {
+					today.Targets[unit.ID] = unit.UnitTargets.scaled(today.Week)
+				}
+			}
+		}
+		workouts = append(workouts, today)
+	}
+
+	data := map[string]any{
+		"Workouts": workouts,
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		data["Error"] = err.Error()
+	}
+	component := htmx.NewComponent("templates/components/program_today.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber)
+	a.render(c, &component)
+}
+
+// programWorkout returns the plan and week of the program for today.
+func (a *App) programWorkout(id string, now time.Time) (uint, ProgramWeek, error) {
+	program, err := a.loadProgram(id)
+	if err != nil {
+		return 0, ProgramWeek{}, err
+	}
+	week := program.week(now)
+	if week < 1 || week > program.Weeks {
+		return 0, ProgramWeek{}, errors.New("program '" + program.Name + "' is not running")
+	}
+	idx := slices.IndexFunc(program.Days, func(d ProgramDay) bool { return d.Weekday == weekday(now) })
+	if idx < 0 {
+		return 0, ProgramWeek{}, errors.New("today is a rest day in '" + program.Name + "'")
+	}
+	return program.Days[idx].PlanID, program.weekPlan(week), nil
+}
+
+func (a *App) loadProgram(id any) (Program, error) {
+	return gorm.G[Program](a.db).
+		Preload("Days", func(db gorm.PreloadBuilder) error {
+			db.Order("weekday")
+			return nil
+		}).
+		Preload("Days.Plan", nil).
+		Preload("WeekPlans", nil).
+		Where("id = ?", id).
+		First(*a.ctx)
+}
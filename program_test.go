@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	programCols     = []string{"ID", "CreatedAt", "UpdatedAt", "Name", "Weeks", "StartedOn"}
+	programDayCols  = []string{"ID", "ProgramID", "Weekday", "PlanID"}
+	programWeekCols = []string{"ID", "ProgramID", "Week", "Intensity", "Deload"}
+	programStart    = time.Date(2025, 10, 6, 0, 0, 0, 0, time.Local)
+	// programNow is the wednesday of the second week of the program
+	programNow = time.Date(2025, 10, 15, 12, 0, 0, 0, time.Local)
+)
+
+// expectLoadProgram expects the queries of a program with the plan on
+// wednesdays, the second week is a deload at 80%.
+func expectLoadProgram() {
+	mocksql.ExpectQuery(`SELECT * FROM "programs" WHERE id = $1 ORDER BY "programs"."id" LIMIT $2`).
+		WithArgs("1", 1).
+		WillReturnRows(sqlmock.NewRows(programCols).AddRow(1, t1, t1, "Strength", 4, programStart))
+	mocksql.ExpectQuery(`SELECT * FROM "program_days" WHERE "program_days"."program_id" = $1 ORDER BY weekday`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(programDayCols).AddRow(2, 1, 2, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "program_weeks" WHERE "program_weeks"."program_id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(programWeekCols).AddRow(3, 1, 1, 90.0, false).AddRow(4, 1, 2, 80.0, true))
+}
+
+func TestProgramWeeks(t *testing.T) {
+	program := Program{ID: 1, Weeks: 4, WeekPlans: []ProgramWeek{{ProgramID: 1, Week: 4, Intensity: 60, Deload: true}}}
+	assert.Equal(t, 0, program.week(programStart))
+
+	program.StartedOn = &programStart
+	assert.Equal(t, 0, program.week(programStart.AddDate(0, 0, -1)))
+	assert.Equal(t, 1, program.week(programStart.AddDate(0, 0, 6)))
+	assert.Equal(t, 2, program.week(programStart.AddDate(0, 0, 7)))
+	// the week containing the change to winter time
+	assert.Equal(t, 5, program.week(time.Date(2025, 11, 3, 12, 0, 0, 0, time.Local)))
+
+	assert.Equal(t, ProgramWeek{ProgramID: 1, Week: 2, Intensity: 100}, program.weekPlan(2))
+	weeks := program.allWeeks()
+	assert.Len(t, weeks, 4)
+	assert.True(t, weeks[3].Deload)
+
+	targets := UnitTargets{Sets: 5, Reps: 5, Weight: 82.5}
+	assert.Equal(t, UnitTargets{Sets: 5, Reps: 5, Weight: 82.5}, targets.scaled(program.weekPlan(1)))
+	assert.Equal(t, UnitTargets{Sets: 3, Reps: 5, Weight: 49.5}, targets.scaled(program.weekPlan(4)))
+
+	assert.Equal(t, 0, weekday(programStart))
+	assert.Equal(t, 6, weekday(programStart.AddDate(0, 0, -1)))
+}
+
+func TestProgram(t *testing.T) {
+	router, app := SetupTestApp()
+	app.mockNow = &programNow
+
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		fixture string
+		expect  func()
+	}{
+		{"GET", "/program/1", nil, "read.html", func() {}},
+		{"POST", "/program/1/week/2", url.Values{"intensity": {"80"}, "deload": {"true"}}, "week.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectQuery(`INSERT INTO "program_weeks" ("program_id","week","intensity","deload") VALUES ($1,$2,$3,$4) ON CONFLICT ("program_id","week") DO UPDATE SET "intensity"="excluded"."intensity","deload"="excluded"."deload" RETURNING "id"`).
+				WithArgs(1, 2, 80.0, true).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+			mocksql.ExpectCommit()
+		}},
+		{"POST", "/program/1/week/2", url.Values{"intensity": {"0"}}, "week_invalid.html", func() {}},
+		{"POST", "/program/1/day", url.Values{"weekday": {"2"}, "plan": {"1"}}, "day.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectQuery(`INSERT INTO "program_days" ("program_id","weekday","plan_id") VALUES ($1,$2,$3) ON CONFLICT ("program_id","weekday") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+				WithArgs(1, 2, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+			mocksql.ExpectCommit()
+		}},
+		{"POST", "/program/1/start", url.Values{"date": {"2025-10-08"}}, "start.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`UPDATE "programs" SET "started_on"=$1,"updated_at"=$2 WHERE id = $3`).
+				WithArgs(programStart, sqlmock.AnyArg(), "1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			test.expect()
+			expectLoadProgram()
+			mocksql.ExpectQuery(`SELECT * FROM "plans" ORDER BY name`).
+				WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, filepath.Join("./fixtures/program", test.fixture), w)
+			if err := mocksql.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestTodaysWorkouts(t *testing.T) {
+	router, app := SetupTestApp()
+	app.mockNow = &programNow
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/program/today", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "programs" WHERE started_on IS NOT NULL ORDER BY name`).
+		WillReturnRows(sqlmock.NewRows(programCols).
+			AddRow(1, t1, t1, "Strength", 4, programStart).
+			AddRow(2, t1, t1, "Finished", 1, programStart).
+			AddRow(3, t1, t1, "Cardio", 2, programStart))
+	mocksql.ExpectQuery(`SELECT * FROM "program_days" WHERE "program_days"."program_id" IN ($1,$2,$3)`).
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows(programDayCols).AddRow(2, 1, 2, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "program_weeks" WHERE "program_weeks"."program_id" IN ($1,$2,$3)`).
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows(programWeekCols).AddRow(3, 1, 2, 90.0, false).AddRow(4, 3, 2, 60.0, true))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE id = $1 ORDER BY "plans"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1 ORDER BY position, id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 0, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."set_id" = $1 ORDER BY position, id`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/program/today.html", w)
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestStartProgramWorkout(t *testing.T) {
+	router, app := SetupTestApp()
+	app.mockNow = &programNow
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/workout?program=1", nil)
+	req.Header.Set("HX-Request", "true")
+	expectLoadProgram()
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE id = $1 ORDER BY "plans"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1 ORDER BY position, id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 0, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."set_id" = $1 ORDER BY position, id`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectBegin()
+	expectNewPlanVersion()
+	mocksql.ExpectQuery(`INSERT INTO "workouts" ("created_at","updated_at","plan_id","plan_version_id","finished_at","program_id","week","intensity","deload") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 2, nil, 1, 2, 80.0, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "workout_units" ("workout_id","unit_id","position","exercise_id") VALUES ($1,$2,$3,$4) ON CONFLICT ("id") DO UPDATE SET "workout_id"="excluded"."workout_id" RETURNING "id"`).
+		WithArgs(5, 7, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mocksql.ExpectCommit()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, `{"path":"/workout/5", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestFinishDeloadWorkout(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/workout/5/finish", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`UPDATE "workouts" SET "finished_at"=$1,"updated_at"=$2 WHERE id = $3 AND finished_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "Intensity", "Deload"}).AddRow(5, 60.0, true))
+	mocksql.ExpectCommit()
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "FinishedAt", "ProgramID", "Week", "Intensity", "Deload"}).AddRow(5, t2, 1, 4, 60.0, true))
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/program/finish_deload.html", w)
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
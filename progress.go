@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// ProgressFilter limits the progress to the sessions between the dates,
+// both are optional and inclusive. Variations rolls up the sets of the whole
+// variation family of the exercise.
+type ProgressFilter struct {
+	From       string `form:"from"`
+	To         string `form:"to"`
+	Variations bool   `form:"variations"`
+}
+
+// Session is the sets of an exercise logged in one workout.
+type Session struct {
+	WorkoutID    uint
+	Date         time.Time
+	Sets         []LoggedSet
+	TopSet       LoggedSet
+	EstimatedMax float64
+	Volume       float64
+}
+
+// ChartPoint is a value of the progress chart in svg coordinates, H is the
+// height of a bar.
+type ChartPoint struct {
+	X, Y, H float64
+	Title   string
+}
+
+// ProgressChart shows the top set and estimated one rep max of the sessions
+// as lines on a common weight scale, the volume as bars on its own scale.
+type ProgressChart struct {
+	TopSet       []ChartPoint
+	EstimatedMax []ChartPoint
+	Volume       []ChartPoint
+	MaxWeight    float64
+	MaxVolume    float64
+	First, Last  string
+}
+
+const (
+	chartWidth  = 300
+	chartHeight = 120
+	chartLeft   = 30
+	chartTop    = 10
+	chartBar    = 4
+)
+
+// progressSet is a logged set with the workout it belongs to.
+type progressSet struct {
+	LoggedSet `gorm:"embedded"`
+	WorkoutID uint
+}
+
+// ExerciseProgress lists the logged sets of the exercise grouped by session
+// with a chart of the progress over time, sets of the timer are not counted.
+func (a *App) ExerciseProgress(c *gin.Context) {
+	var filter ProgressFilter
+	exercise, err := gorm.G[Exercise](a.db).Where("id = ?", c.Param("id")).First(*a.ctx)
+	if err == nil {
+		err = c.ShouldBindWith(&filter, binding.Query)
+	}
+	var from, to time.Time
+	if err == nil {
+		from, to, err = filter.dates()
+	}
+	ids := []uint{exercise.ID}
+	variations := map[uint]string{}
+	if err == nil && filter.Variations {
+		var family []Variation
+		family, err = a.family(exercise.ID)
+		for _, variation := range family {
+			if variation.ID != exercise.ID {
+				ids = append(ids, variation.ID)
+				variations[variation.ID] = variation.Name
+			}
+		}
+	}
+	var sets []progressSet
+	if err == nil {
+		query := a.db.WithContext(*a.ctx).
+			Table("logged_sets").
+			Select("logged_sets.*, workout_units.workout_id").
+			Joins("JOIN workout_units ON workout_units.id = logged_sets.workout_unit_id").
+			Where("logged_sets.exercise_id IN ?", ids).
+			Where("logged_sets.timer_round = 0")
+		if !from.IsZero() {
+			query = query.Where("logged_sets.created_at >= ?", from)
+		}
+		if !to.IsZero() {
+			query = query.Where("logged_sets.created_at < ?", to)
+		}
+		err = query.Order("logged_sets.created_at, logged_sets.id").Scan(&sets).Error
+	}
+	if err != nil {
+		log.Printf("progress error: %v", err)
+	}
+
+	sessions := groupSessions(sets)
+	data := map[string]any{
+		"Exercise":   exercise,
+		"Filter":     filter,
+		"Variations": variations,
+		"Sessions":   sessions,
+		"Chart":      progressChart(sessions),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/progress.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber).
+		AddTemplateFunction("points", chartPoints).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// dates parses the filter, the end is the start of the day after To.
+func (f ProgressFilter) dates() (from time.Time, to time.Time, err error) {
+	if f.From != "" {
+		from, err = time.ParseInLocation(time.DateOnly, f.From, time.Local)
+		if err != nil {
+			return from, to, errors.New("invalid start date '" + f.From + "'")
+		}
+	}
+	if f.To != "" {
+		to, err = time.ParseInLocation(time.DateOnly, f.To, time.Local)
+		if err != nil {
+			return from, to, errors.New("invalid end date '" + f.To + "'")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("the start date is after the end date")
+	}
+	return from, to, nil
+}
+
+// groupSessions groups the sets ordered by time by their workout, the top
+// set is the heaviest one with the most reps.
+func groupSessions(sets []progressSet) []Session {
+	sessions := []Session{}
+	index := map[uint]int{}
+	for _, set := range sets {
+		i, ok := index[set.WorkoutID]
+		if !ok {
+			i = len(sessions)
+			index[set.WorkoutID] = i
+			sessions = append(sessions, Session{WorkoutID: set.WorkoutID, Date: set.CreatedAt})
+		}
+		session := &sessions[i]
+		session.Sets = append(session.Sets, set.LoggedSet)
+		if set.Weight > session.TopSet.Weight ||
+			set.Weight == session.TopSet.Weight && set.Reps > session.TopSet.Reps {
+			session.TopSet = set.LoggedSet
+		}
+		session.EstimatedMax = max(session.EstimatedMax, set.EstimatedMax)
+		session.Volume += float64(set.Reps) * set.Weight
+	}
+	return sessions
+}
+
+// progressChart places the sessions by their date, a single session is
+// drawn in the middle.
+func progressChart(sessions []Session) ProgressChart {
+	chart := ProgressChart{}
+	if len(sessions) == 0 {
+		return chart
+	}
+	for _, session := range sessions {
+		chart.MaxWeight = max(chart.MaxWeight, session.TopSet.Weight, session.EstimatedMax)
+		chart.MaxVolume = max(chart.MaxVolume, session.Volume)
+	}
+	first, last := sessions[0].Date, sessions[len(sessions)-1].Date
+	chart.First, chart.Last = first.Format(time.DateOnly), last.Format(time.DateOnly)
+	span := last.Sub(first)
+
+	y := func(value, highest float64) float64 {
+		if highest <= 0 {
+			return chartTop + chartHeight
+		}
+		return chartTop + chartHeight*(1-value/highest)
+	}
+	for _, session := range sessions {
+		x := float64(chartLeft + chartWidth/2)
+		if span > 0 {
+			x = chartLeft + chartWidth*float64(session.Date.Sub(first))/float64(span)
+		}
+		date := session.Date.Format(time.DateOnly)
+		chart.TopSet = append(chart.TopSet, ChartPoint{
+			X: x, Y: y(session.TopSet.Weight, chart.MaxWeight),
+			Title: fmt.Sprintf("%s: top set %d × %s", date, session.TopSet.Reps, formatNumber(session.TopSet.Weight)),
+		})
+		chart.EstimatedMax = append(chart.EstimatedMax, ChartPoint{
+			X: x, Y: y(session.EstimatedMax, chart.MaxWeight),
+			Title: date + ": est. 1RM " + formatNumber(session.EstimatedMax),
+		})
+		top := y(session.Volume, chart.MaxVolume)
+		chart.Volume = append(chart.Volume, ChartPoint{
+			X: x - chartBar/2, Y: top, H: chartTop + chartHeight - top,
+			Title: date + ": volume " + formatNumber(session.Volume),
+		})
+	}
+	return chart
+}
+
+// chartPoints formats the points for a polyline.
+func chartPoints(points []ChartPoint) string {
+	coordinates := make([]string, len(points))
+	for i, point := range points {
+		coordinates[i] = formatNumber(point.X) + "," + formatNumber(point.Y)
+	}
+	return strings.Join(coordinates, " ")
+}
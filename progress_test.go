@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	progressCols = []string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight", "EstimatedMax", "workout_id"}
+	session1     = time.Date(2025, 10, 6, 18, 0, 0, 0, time.Local)
+	session2     = time.Date(2025, 10, 9, 18, 0, 0, 0, time.Local)
+)
+
+func progressRows() *sqlmock.Rows {
+	return sqlmock.NewRows(progressCols).
+		AddRow(1, session1, 3, 2, 5, 60.0, 70.0, 5).
+		AddRow(2, session1.Add(3*time.Minute), 3, 2, 8, 60.0, 76.0, 5).
+		AddRow(3, session2, 4, 2, 5, 65.0, 75.83, 6)
+}
+
+func TestProgressChart(t *testing.T) {
+	sessions := groupSessions([]progressSet{
+		{LoggedSet{ID: 1, CreatedAt: session1, Reps: 5, Weight: 60, EstimatedMax: 70}, 5},
+		{LoggedSet{ID: 2, CreatedAt: session1, Reps: 8, Weight: 60, EstimatedMax: 76}, 5},
+		{LoggedSet{ID: 3, CreatedAt: session2, Reps: 5, Weight: 65, EstimatedMax: 75.83}, 6},
+	})
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, uint(2), sessions[0].TopSet.ID)
+	assert.Equal(t, 76.0, sessions[0].EstimatedMax)
+	assert.Equal(t, 780.0, sessions[0].Volume)
+	assert.Len(t, sessions[0].Sets, 2)
+
+	chart := progressChart(sessions)
+	assert.Equal(t, 76.0, chart.MaxWeight)
+	assert.Equal(t, 780.0, chart.MaxVolume)
+	assert.Equal(t, "30,35.26 330,27.37", chartPoints(chart.TopSet))
+	assert.Equal(t, ChartPoint{X: 28, Y: 10, H: 120, Title: "2025-10-06: volume 780"}, chart.Volume[0])
+
+	chart = progressChart(sessions[1:])
+	assert.Equal(t, 180.0, chart.TopSet[0].X)
+	assert.Equal(t, ProgressChart{}, progressChart(nil))
+}
+
+func TestProgressFilter(t *testing.T) {
+	from, to, err := ProgressFilter{From: "2025-10-06", To: "2025-10-06"}.dates()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 6, 0, 0, 0, 0, time.Local), from)
+	assert.Equal(t, time.Date(2025, 10, 7, 0, 0, 0, 0, time.Local), to)
+
+	_, _, err = ProgressFilter{From: "2025-10-07", To: "2025-10-06"}.dates()
+	assert.EqualError(t, err, "the start date is after the end date")
+	_, _, err = ProgressFilter{To: "tomorrow"}.dates()
+	assert.EqualError(t, err, "invalid end date 'tomorrow'")
+}
+
+func TestExerciseProgress(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	expectExercise := func() {
+		mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+			WithArgs("2", 1).
+			WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	}
+	tests := []struct {
+		url     string
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"/exercise/2/progress",
+			func() {
+				expectExercise()
+				mocksql.ExpectQuery(`SELECT logged_sets.*, workout_units.workout_id FROM "logged_sets" JOIN workout_units ON workout_units.id = logged_sets.workout_unit_id WHERE logged_sets.exercise_id IN ($1) AND logged_sets.timer_round = 0 ORDER BY logged_sets.created_at, logged_sets.id`).
+					WithArgs(2).
+					WillReturnRows(progressRows())
+			},
+			"./fixtures/progress/all.html",
+		},
+		{
+			"/exercise/2/progress?from=2025-10-01&to=2025-10-09",
+			func() {
+				expectExercise()
+				mocksql.ExpectQuery(`SELECT logged_sets.*, workout_units.workout_id FROM "logged_sets" JOIN workout_units ON workout_units.id = logged_sets.workout_unit_id WHERE logged_sets.exercise_id IN ($1) AND logged_sets.timer_round = 0 AND logged_sets.created_at >= $2 AND logged_sets.created_at < $3 ORDER BY logged_sets.created_at, logged_sets.id`).
+					WithArgs(2, time.Date(2025, 10, 1, 0, 0, 0, 0, time.Local), time.Date(2025, 10, 10, 0, 0, 0, 0, time.Local)).
+					WillReturnRows(progressRows())
+			},
+			"./fixtures/progress/filtered.html",
+		},
+		{
+			"/exercise/2/progress?variations=true",
+			func() {
+				expectExercise()
+				mocksql.ExpectQuery(`WITH RECURSIVE up AS (
+						SELECT id, parent_id, 0 AS depth FROM exercises WHERE id = $1
+						UNION ALL
+						SELECT e.id, e.parent_id, up.depth + 1 FROM exercises e JOIN up ON e.id = up.parent_id
+						WHERE up.depth < $2
+					)
+					SELECT id FROM up ORDER BY depth`).
+					WithArgs(2, maxDepth).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mocksql.ExpectQuery(`WITH RECURSIVE family AS (
+						SELECT exercises.*, 0 AS depth, ARRAY[name]::text[] AS path FROM exercises WHERE id = $1
+						UNION ALL
+						SELECT e.*, family.depth + 1, family.path || e.name::text FROM exercises e JOIN family ON e.parent_id = family.id
+						WHERE family.depth < $2
+					)
+					SELECT * FROM family ORDER BY path`).
+					WithArgs(2, maxDepth).
+					WillReturnRows(sqlmock.NewRows([]string{"ID", "Name", "ParentID", "Depth"}).
+						AddRow(2, "bla", nil, 0).
+						AddRow(8, "Paused bla", 2, 1))
+				mocksql.ExpectQuery(`SELECT logged_sets.*, workout_units.workout_id FROM "logged_sets" JOIN workout_units ON workout_units.id = logged_sets.workout_unit_id WHERE logged_sets.exercise_id IN ($1,$2) AND logged_sets.timer_round = 0 ORDER BY logged_sets.created_at, logged_sets.id`).
+					WithArgs(2, 8).
+					WillReturnRows(progressRows().AddRow(4, session2.Add(5*time.Minute), 9, 8, 3, 70.0, 77.0, 6))
+			},
+			"./fixtures/progress/variations.html",
+		},
+		{
+			"/exercise/2/progress?from=2025-10-09&to=2025-10-01",
+			expectExercise,
+			"./fixtures/progress/invalid_range.html",
+		},
+		{
+			"/exercise/2/progress?to=2025-10-01",
+			func() {
+				expectExercise()
+				mocksql.ExpectQuery(`SELECT logged_sets.*, workout_units.workout_id FROM "logged_sets" JOIN workout_units ON workout_units.id = logged_sets.workout_unit_id WHERE logged_sets.exercise_id IN ($1) AND logged_sets.timer_round = 0 AND logged_sets.created_at < $2 ORDER BY logged_sets.created_at, logged_sets.id`).
+					WithArgs(2, time.Date(2025, 10, 2, 0, 0, 0, 0, time.Local)).
+					WillReturnRows(sqlmock.NewRows(progressCols))
+			},
+			"./fixtures/progress/empty.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// Progression is the rule moving the targets of a plan unit on after a
+// workout.
+type Progression = Enum[progressionNames]
+
+const (
+	NoProgression         Progression = "None"
+	LinearProgression     Progression = "Linear"
+	DoubleProgression     Progression = "Double"
+	PercentageProgression Progression = "Percentage"
+	RPEProgression        Progression = "RPE"
+)
+
+type progressionNames struct{}
+
+func (progressionNames) names() []string {
+	return []string{"None", "Linear", "Double", "Percentage", "RPE"}
+}
+
+// UnitTargets are the sets, reps and weight planned for the next session of
+// a unit and the rule that computes them from the sets logged.
+type UnitTargets struct {
+	Sets        int         `form:"sets" binding:"gte=0"`
+	Reps        int         `form:"reps" binding:"gte=0"`
+	Weight      float64     `form:"weight" binding:"gte=0"`
+	Progression Progression `form:"progression,default=None" binding:"enum" gorm:"not null;default:None"`
+	// Increment is added to the weight, for the percentage rule to the
+	// training max
+	Increment float64 `form:"increment" binding:"gte=0"`
+	// MinReps and MaxReps are the rep range of the double progression
+	MinReps int `form:"min_reps" binding:"gte=0"`
+	MaxReps int `form:"max_reps" binding:"gte=0"`
+	// the weight of the percentage rule is Percentage of the TrainingMax
+	TrainingMax float64 `form:"training_max" binding:"gte=0"`
+	Percentage  float64 `form:"percentage" binding:"gte=0,lte=100"`
+	// RPE is the effort the sets of the RPE rule should feel like
+	RPE float64 `form:"rpe" binding:"gte=0,lte=10"`
+}
+
+// validate checks the settings of the rule and derives the weight of the
+// percentage rule.
+func (t *UnitTargets) validate() error {
+	switch t.Progression {
+	case DoubleProgression:
+		if t.MaxReps < t.MinReps {
+			return errors.New("the rep range ends below its start")
+		}
+		if t.Reps == 0 {
+			t.Reps = t.MinReps
+		}
+	case PercentageProgression:
+		if t.TrainingMax == 0 || t.Percentage == 0 {
+			return errors.New("the percentage rule needs a training max and a percentage")
+		}
+		t.Weight = t.percentageWeight()
+	case RPEProgression:
+		if t.RPE == 0 {
+			return errors.New("the RPE rule needs a target RPE")
+		}
+	}
+	return nil
+}
+
+// met tells whether the target number of sets were done with at least the
+// target reps and weight, sets of the timer are not counted.
+func (t UnitTargets) met(sets []LoggedSet) bool {
+	done, performed := 0, 0
+	for _, set := range sets {
+		if set.TimerRound > 0 {
+			continue
+		}
+		performed++
+		if set.Reps >= t.Reps && set.Weight >= t.Weight {
+			done++
+		}
+	}
+	return performed > 0 && done >= max(t.Sets, 1)
+}
+
+// progress returns the targets of the next session from the sets logged in
+// this one, done at the intensity in percent of the targets.
+func (t UnitTargets) progress(sets []LoggedSet, intensity float64) UnitTargets {
+	met := t.scaled(ProgramWeek{Intensity: intensity}).met(sets)
+	switch t.Progression {
+	case LinearProgression:
+		if met {
+			t.Weight += t.Increment
+		}
+	case DoubleProgression:
+		// more reps until the top of the range, then more weight from
+		// the bottom of the range
+		if met && t.Reps >= t.MaxReps {
+			t.Weight += t.Increment
+			t.Reps = t.MinReps
+		} else if met {
+			t.Reps++
+		}
+	case PercentageProgression:
+		if met {
+			t.TrainingMax += t.Increment
+		}
+		t.Weight = t.percentageWeight()
+	case RPEProgression:
+		rpe, rated := 0.0, 0
+		for _, set := range sets {
+			if set.RPE > 0 {
+				rpe += set.RPE
+				rated++
+			}
+		}
+		if rated == 0 {
+			break
+		}
+		rpe /= float64(rated)
+		if met && rpe < t.RPE-0.5 {
+			t.Weight += t.Increment
+		} else if !met || rpe > t.RPE+0.5 {
+			t.Weight = max(t.Weight-t.Increment, 0)
+		}
+	}
+	return t
+}
+
+// percentageWeight returns the percentage of the training max rounded to a
+// quarter.
+func (t UnitTargets) percentageWeight() float64 {
+	return math.Round(t.TrainingMax*t.Percentage/100*4) / 4
+}
+
+// progressUnits moves the targets of the plan units performed in the
+// workout on, units swapped to another exercise are skipped.
+func (a *App) progressUnits(tx *gorm.DB, workout Workout) error {
+	units, err := gorm.G[WorkoutUnit](tx).
+		Preload("Unit", nil).
+		Preload("Sets", nil).
+		Where("workout_id = ?", workout.ID).
+		Find(*a.ctx)
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if unit.Unit == nil || unit.Unit.ExerciseID != unit.ExerciseID {
+			continue
+		}
+		targets := unit.Unit.UnitTargets.progress(unit.Sets, workout.Intensity)
+		if targets == unit.Unit.UnitTargets {
+			continue
+		}
+		_, err = gorm.G[Unit](tx).
+			Where("id = ?", unit.Unit.ID).
+			Select("target_reps", "target_weight", "target_training_max").
+			Updates(*a.ctx, Unit{UnitTargets: targets})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
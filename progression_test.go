@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	done := []LoggedSet{{Reps: 5, Weight: 60}, {Reps: 5, Weight: 60}, {Reps: 5, Weight: 60}}
+	missed := []LoggedSet{{Reps: 5, Weight: 60}, {Reps: 4, Weight: 60}, {Reps: 3, Weight: 60}}
+
+	linear := UnitTargets{Sets: 3, Reps: 5, Weight: 60, Progression: LinearProgression, Increment: 2.5}
+	assert.Equal(t, 62.5, linear.progress(done, 100).Weight)
+	assert.Equal(t, linear, linear.progress(missed, 100))
+	assert.Equal(t, linear, linear.progress(nil, 100))
+	timer := []LoggedSet{{Reps: 5, Weight: 60, TimerRound: 1}, {Reps: 5, Weight: 60, TimerRound: 2}, {Reps: 5, Weight: 60, TimerRound: 3}}
+	assert.Equal(t, linear, linear.progress(timer, 100))
+
+	double := UnitTargets{Sets: 3, Reps: 4, Weight: 60, Progression: DoubleProgression, Increment: 5, MinReps: 3, MaxReps: 5}
+	assert.Equal(t, 5, double.progress(done, 100).Reps)
+	double.Reps = 5
+	next := double.progress(done, 100)
+	assert.Equal(t, 3, next.Reps)
+	assert.Equal(t, 65.0, next.Weight)
+
+	percentage := UnitTargets{Sets: 3, Reps: 5, Weight: 60, Progression: PercentageProgression, Increment: 5, TrainingMax: 80, Percentage: 75}
+	next = percentage.progress(done, 100)
+	assert.Equal(t, 85.0, next.TrainingMax)
+	assert.Equal(t, 63.75, next.Weight)
+
+	rpe := UnitTargets{Sets: 3, Reps: 5, Weight: 60, Progression: RPEProgression, Increment: 2.5, RPE: 8}
+	assert.Equal(t, rpe, rpe.progress(done, 100))
+	easy := []LoggedSet{{Reps: 5, Weight: 60, RPE: 6}, {Reps: 5, Weight: 60, RPE: 7}, {Reps: 5, Weight: 60}}
+	assert.Equal(t, 62.5, rpe.progress(easy, 100).Weight)
+	hard := []LoggedSet{{Reps: 5, Weight: 60, RPE: 9}, {Reps: 5, Weight: 60, RPE: 9.5}, {Reps: 5, Weight: 60, RPE: 10}}
+	assert.Equal(t, 57.5, rpe.progress(hard, 100).Weight)
+
+	none := UnitTargets{Sets: 3, Reps: 5, Weight: 60, Progression: NoProgression, Increment: 2.5}
+	assert.Equal(t, none, none.progress(done, 100))
+}
+
+func TestUnitTargetsValidate(t *testing.T) {
+	targets := UnitTargets{Progression: DoubleProgression, MinReps: 8, MaxReps: 6}
+	assert.EqualError(t, targets.validate(), "the rep range ends below its start")
+	targets.MaxReps = 12
+	assert.NoError(t, targets.validate())
+	assert.Equal(t, 8, targets.Reps)
+
+	targets = UnitTargets{Progression: PercentageProgression, TrainingMax: 100, Percentage: 72.5}
+	assert.NoError(t, targets.validate())
+	assert.Equal(t, 72.5, targets.Weight)
+	assert.Error(t, (&UnitTargets{Progression: PercentageProgression}).validate())
+	assert.Error(t, (&UnitTargets{Progression: RPEProgression}).validate())
+}
+
+func TestSaveUnit(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		form    url.Values
+		dbmocks func()
+		fixture string
+	}{
+		{
+			url.Values{"sets": {"3"}, "reps": {"5"}, "weight": {"60"}, "progression": {"Linear"}, "increment": {"2.5"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`UPDATE "units" SET "target_sets"=$1,"target_reps"=$2,"target_weight"=$3,"target_progression"=$4,"target_increment"=$5,"target_min_reps"=$6,"target_max_reps"=$7,"target_training_max"=$8,"target_percentage"=$9,"target_rpe"=$10 WHERE id = $11 AND set_id IN (SELECT id FROM sets WHERE plan_id = $12)`).
+					WithArgs(3, 5, 60.0, LinearProgression, 2.5, 0, 0, 0.0, 0.0, 0.0, "7", "1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectLoadPlan()
+			},
+			"./fixtures/plan/unit_saved.html",
+		},
+		{
+			url.Values{"progression": {"Double"}, "min_reps": {"8"}, "max_reps": {"6"}},
+			expectLoadPlan,
+			"./fixtures/plan/unit_invalid.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/plan/1/unit/7", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestFinishWorkout(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	setCols := []string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight"}
+	sets := func() *sqlmock.Rows {
+		return sqlmock.NewRows(setCols).
+			AddRow(1, t2, 3, 2, 5, 60.0).
+			AddRow(2, t2, 3, 2, 5, 60.0).
+			AddRow(3, t2, 3, 2, 6, 60.0)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/workout/5/finish", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`UPDATE "workouts" SET "finished_at"=$1,"updated_at"=$2 WHERE id = $3 AND finished_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "Intensity", "Deload"}).AddRow(5, 100.0, false))
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE workout_id = $1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1`).
+		WithArgs(3).
+		WillReturnRows(sets())
+	expectUnit()
+	mocksql.ExpectExec(`UPDATE "units" SET "target_reps"=$1,"target_weight"=$2,"target_training_max"=$3 WHERE id = $4`).
+		WithArgs(5, 62.5, 0.0, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, 1, t2, 100.0))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	expectPlanSets()
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1 ORDER BY id`).
+		WithArgs(3).
+		WillReturnRows(sets())
+	mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE "personal_records"."logged_set_id" IN ($1,$2,$3) ORDER BY id`).
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows(recordCols))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."id" = $1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 62.5, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/workout/finish.html", w)
+}
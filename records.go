@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Formula estimates the one rep max from the weight and reps of a set.
+type Formula = Enum[formulaNames]
+
+const (
+	Epley   Formula = "Epley"
+	Brzycki Formula = "Brzycki"
+)
+
+type formulaNames struct{}
+
+func (formulaNames) names() []string {
+	return []string{"Epley", "Brzycki"}
+}
+
+// estimateMax returns the estimated one rep max, Brzycki is not defined for
+// more than 36 reps so Epley is used for those.
+func estimateMax(formula Formula, weight float64, reps int) float64 {
+	switch {
+	case reps <= 0:
+		return 0
+	case reps == 1:
+		return weight
+	case formula == Brzycki && reps < 37:
+		return weight * 36 / float64(37-reps)
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+// RecordKind is what a personal record was set in.
+type RecordKind = Enum[recordKindNames]
+
+const (
+	MaxWeight    RecordKind = "max weight"
+	RepsAtWeight RecordKind = "reps at weight"
+	EstimatedMax RecordKind = "est. 1RM"
+	SetVolume    RecordKind = "volume"
+)
+
+type recordKindNames struct{}
+
+func (recordKindNames) names() []string {
+	return []string{"max weight", "reps at weight", "est. 1RM", "volume"}
+}
+
+// PersonalRecord is a logged set that beat the best earlier set of the
+// exercise, Weight is the weight of the set for records in reps.
+type PersonalRecord struct {
+	ID          uint
+	CreatedAt   time.Time
+	LoggedSetID uint `gorm:"index"`
+	ExerciseID  uint `gorm:"index"`
+	Kind        RecordKind
+	Weight      float64
+	Value       float64
+	Previous    float64
+}
+
+// RecordSettings are the users settings for estimating the one rep max.
+type RecordSettings struct {
+	Username  string `gorm:"primaryKey"`
+	UpdatedAt time.Time
+	Formula   Formula `form:"formula,default=Epley" binding:"enum"`
+}
+
+// bestSets are the best values of the earlier sets of an exercise, nil
+// without an earlier set.
+type bestSets struct {
+	Weight       *float64
+	Reps         *int
+	EstimatedMax *float64
+	Volume       *float64
+}
+
+// personalRecords lists the records set by the set, the first set of an
+// exercise or weight sets none.
+func personalRecords(set LoggedSet, best bestSets) []PersonalRecord {
+	records := []PersonalRecord{}
+	add := func(kind RecordKind, value, previous float64) {
+		records = append(records, PersonalRecord{
+			ExerciseID: set.ExerciseID,
+			Kind:       kind,
+			Weight:     set.Weight,
+			Value:      value,
+			Previous:   previous,
+		})
+	}
+	if set.Reps <= 0 {
+		return records
+	}
+	if best.Weight != nil && set.Weight > *best.Weight {
+		add(MaxWeight, set.Weight, *best.Weight)
+	}
+	if best.Reps != nil && set.Reps > *best.Reps {
+		add(RepsAtWeight, float64(set.Reps), float64(*best.Reps))
+	}
+	if best.EstimatedMax != nil && set.EstimatedMax > *best.EstimatedMax {
+		add(EstimatedMax, set.EstimatedMax, *best.EstimatedMax)
+	}
+	volume := float64(set.Reps) * set.Weight
+	if best.Volume != nil && volume > *best.Volume {
+		add(SetVolume, volume, *best.Volume)
+	}
+	return records
+}
+
+// bestEstimate returns the highest one rep max of the sets estimated with the
+// formula, nil without sets.
+func bestEstimate(formula Formula, sets []LoggedSet) *float64 {
+	if len(sets) == 0 {
+		return nil
+	}
+	best := 0.0
+	for _, set := range sets {
+		best = max(best, estimateMax(formula, set.Weight, set.Reps))
+	}
+	return &best
+}
+
+// logSet stores the set with its estimated one rep max and the personal
+// records it sets, sets of the timer are stored as they are.
+func (a *App) logSet(c *gin.Context, set *LoggedSet) error {
+	if set.TimerRound > 0 {
+		return gorm.G[LoggedSet](a.db).Create(*a.ctx, set)
+	}
+	formula, err := a.formula(c)
+	if err != nil {
+		return err
+	}
+	set.EstimatedMax = estimateMax(formula, set.Weight, set.Reps)
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		var best bestSets
+		err := tx.WithContext(*a.ctx).
+			Table("logged_sets").
+			Select("MAX(weight) AS weight, MAX(reps) FILTER (WHERE weight = ?) AS reps, "+
+				"MAX(reps * weight) AS volume", set.Weight).
+			Where("exercise_id = ? AND reps > 0 AND timer_round = 0", set.ExerciseID).
+			Scan(&best).Error
+		if err != nil {
+			return err
+		}
+		// the stored estimates use the formula of their time, the earlier
+		// sets are estimated again to compare them with the same formula
+		earlier, err := gorm.G[LoggedSet](tx).
+			Select("DISTINCT weight, reps").
+			Where("exercise_id = ? AND reps > 0 AND timer_round = 0", set.ExerciseID).
+			Find(*a.ctx)
+		if err != nil {
+			return err
+		}
+		best.EstimatedMax = bestEstimate(formula, earlier)
+		err = gorm.G[LoggedSet](tx).Create(*a.ctx, set)
+		if err != nil {
+			return err
+		}
+		records := personalRecords(*set, best)
+		if len(records) == 0 {
+			return nil
+		}
+		for i := range records {
+			records[i].LoggedSetID = set.ID
+		}
+		return gorm.G[PersonalRecord](tx).CreateInBatches(*a.ctx, &records, len(records))
+	})
+}
+
+// formula returns the formula selected by the user.
+func (a *App) formula(c *gin.Context) (Formula, error) {
+	settings, err := gorm.G[RecordSettings](a.db).Where("username = ?", currentUser(c)).First(*a.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Epley, nil
+	}
+	return settings.Formula, err
+}
+
+// ListRecords shows the personal records of the exercise, latest first.
+func (a *App) ListRecords(c *gin.Context) {
+	a.renderRecords(c, nil)
+}
+
+// SaveFormula stores the formula of the user, it is used for the sets logged
+// from now on.
+func (a *App) SaveFormula(c *gin.Context) {
+	var settings RecordSettings
+	err := c.ShouldBindWith(&settings, binding.Form)
+	if err == nil {
+		settings.Username = currentUser(c)
+		err = gorm.G[RecordSettings](a.db, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "formula"}),
+		}).Create(*a.ctx, &settings)
+	}
+	if err != nil {
+		log.Printf("formula error: %v", err)
+	}
+	a.renderRecords(c, err)
+}
+
+func (a *App) renderRecords(c *gin.Context, err error) {
+	exercise, dbErr := gorm.G[Exercise](a.db).Where("id = ?", c.Param("id")).First(*a.ctx)
+	var records []PersonalRecord
+	if dbErr == nil {
+		records, dbErr = gorm.G[PersonalRecord](a.db).
+			Where("exercise_id = ?", exercise.ID).
+			Order("created_at DESC, id").
+			Find(*a.ctx)
+	}
+	formula := Epley
+	if dbErr == nil {
+		formula, dbErr = a.formula(c)
+	}
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+
+	data := map[string]any{
+		"Exercise": exercise,
+		"Records":  records,
+		"Formula":  formula,
+		"Formulas": enumValues[formulaNames](),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/records.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	recordCols         = []string{"ID", "CreatedAt", "LoggedSetID", "ExerciseID", "Kind", "Weight", "Value", "Previous"}
+	recordSettingsCols = []string{"Username", "UpdatedAt", "Formula"}
+)
+
+func TestEstimateMax(t *testing.T) {
+	assert.Equal(t, 0.0, estimateMax(Epley, 100, 0))
+	assert.Equal(t, 100.0, estimateMax(Brzycki, 100, 1))
+	assert.InDelta(t, 116.67, estimateMax(Epley, 100, 5), 0.01)
+	assert.InDelta(t, 112.5, estimateMax(Brzycki, 100, 5), 0.01)
+	assert.InDelta(t, 233.33, estimateMax(Brzycki, 100, 40), 0.01)
+}
+
+func TestBestEstimate(t *testing.T) {
+	sets := []LoggedSet{{Weight: 100, Reps: 5}, {Weight: 110, Reps: 2}}
+	assert.InDelta(t, 117.33, *bestEstimate(Epley, sets), 0.01)
+	assert.InDelta(t, 113.14, *bestEstimate(Brzycki, sets), 0.01)
+	assert.Nil(t, bestEstimate(Epley, nil))
+}
+
+func TestPersonalRecords(t *testing.T) {
+	weight, reps, e1rm, volume := 100.0, 5, 115.0, 500.0
+	best := bestSets{Weight: &weight, Reps: &reps, EstimatedMax: &e1rm, Volume: &volume}
+
+	set := LoggedSet{ExerciseID: 2, Reps: 6, Weight: 100, EstimatedMax: 120}
+	assert.Equal(t, []PersonalRecord{
+		{ExerciseID: 2, Kind: RepsAtWeight, Weight: 100, Value: 6, Previous: 5},
+		{ExerciseID: 2, Kind: EstimatedMax, Weight: 100, Value: 120, Previous: 115},
+		{ExerciseID: 2, Kind: SetVolume, Weight: 100, Value: 600, Previous: 500},
+	}, personalRecords(set, best))
+
+	set = LoggedSet{ExerciseID: 2, Reps: 1, Weight: 110, EstimatedMax: 110}
+	assert.Equal(t, []PersonalRecord{
+		{ExerciseID: 2, Kind: MaxWeight, Weight: 110, Value: 110, Previous: 100},
+	}, personalRecords(set, bestSets{Weight: &weight, EstimatedMax: &e1rm, Volume: &volume}))
+
+	assert.Empty(t, personalRecords(set, bestSets{}))
+	assert.Empty(t, personalRecords(LoggedSet{Weight: 200}, best))
+}
+
+func TestLogSet(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	form := url.Values{"reps": {"6"}, "weight": {"60"}}
+	req, _ := http.NewRequest("POST", "/workout/5/unit/3/set", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+		WithArgs("3", "5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	expectOpenWorkout(0)
+	mocksql.ExpectQuery(`SELECT * FROM "record_settings" WHERE username = $1 ORDER BY "record_settings"."username" LIMIT $2`).
+		WithArgs("", 1).
+		WillReturnRows(sqlmock.NewRows(recordSettingsCols).AddRow("", t2, "Brzycki"))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT MAX(weight) AS weight, MAX(reps) FILTER (WHERE weight = $1) AS reps, MAX(reps * weight) AS volume FROM "logged_sets" WHERE exercise_id = $2 AND reps > 0 AND timer_round = 0`).
+		WithArgs(60.0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"weight", "reps", "volume"}).AddRow(80.0, 5, 400.0))
+	// 80 × 5 was logged with Epley as 93.33, with Brzycki it is 90 and the
+	// set of 60 × 6 at 69.68 is no record
+	mocksql.ExpectQuery(`SELECT DISTINCT weight, reps FROM "logged_sets" WHERE exercise_id = $1 AND reps > 0 AND timer_round = 0`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"weight", "reps"}).AddRow(80.0, 5).AddRow(60.0, 5))
+	mocksql.ExpectQuery(`INSERT INTO "logged_sets" ("created_at","workout_unit_id","exercise_id","reps","weight","rpe","drop","estimated_max","timer_round") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 3, 2, 6, 60.0, 0.0, false, 60*36/31.0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mocksql.ExpectQuery(`INSERT INTO "personal_records" ("created_at","logged_set_id","exercise_id","kind","weight","value","previous") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 9, 2, RepsAtWeight, 60.0, 6.0, 5.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mocksql.ExpectCommit()
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, nil, nil, 100.0))
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1 ORDER BY id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight", "EstimatedMax"}).
+			AddRow(9, t2, 3, 2, 6, 60.0, 60*36/31.0))
+	mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE "personal_records"."logged_set_id" = $1 ORDER BY id`).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(recordCols).AddRow(4, t2, 9, 2, RepsAtWeight, 60.0, 6.0, 5.0))
+	expectUnit()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/workout/log_set_record.html", w)
+}
+
+func TestRecords(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	expectRecords := func() {
+		mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+			WithArgs("2", 1).
+			WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+		mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE exercise_id = $1 ORDER BY created_at DESC, id`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(recordCols).
+				AddRow(4, t2, 9, 2, RepsAtWeight, 60.0, 6.0, 5.0).
+				AddRow(2, t1, 7, 2, MaxWeight, 80.0, 80.0, 75.0).
+				AddRow(3, t1, 7, 2, EstimatedMax, 80.0, 90.67, 87.5))
+		mocksql.ExpectQuery(`SELECT * FROM "record_settings" WHERE username = $1 ORDER BY "record_settings"."username" LIMIT $2`).
+			WithArgs("", 1).
+			WillReturnRows(sqlmock.NewRows(recordSettingsCols).AddRow("", t2, "Brzycki"))
+	}
+
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		dbmocks func()
+		fixture string
+	}{
+		{"GET", "/exercise/2/records", nil, expectRecords, "./fixtures/records/list.html"},
+		{
+			"POST", "/exercise/2/records/formula", url.Values{"formula": {"Brzycki"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`INSERT INTO "record_settings" ("username","updated_at","formula") VALUES ($1,$2,$3) ON CONFLICT ("username") DO UPDATE SET "updated_at"="excluded"."updated_at","formula"="excluded"."formula"`).
+					WithArgs("", sqlmock.AnyArg(), "Brzycki").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectRecords()
+			},
+			"./fixtures/records/formula.html",
+		},
+		{"POST", "/exercise/2/records/formula", url.Values{"formula": {"Lander"}}, expectRecords, "./fixtures/records/formula_invalid.html"},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// RevisionAction is the change recorded by a revision.
+type RevisionAction = Enum[revisionActionNames]
+
+const (
+	Created  RevisionAction = "created"
+	Updated  RevisionAction = "updated"
+	Trashed  RevisionAction = "trashed"
+	Restored RevisionAction = "restored"
+	Reverted RevisionAction = "reverted"
+)
+
+type revisionActionNames struct{}
+
+func (revisionActionNames) names() []string {
+	return []string{"created", "updated", "trashed", "restored", "reverted"}
+}
+
+// ExerciseRevision records who changed an exercise and how it looked
+// afterwards. Revisions have no foreign key, the history of trashed exercises
+// is kept until they are purged.
+type ExerciseRevision struct {
+	ID         uint
+	CreatedAt  time.Time
+	ExerciseID uint `gorm:"index"`
+	Username   string
+	Action     RevisionAction
+	Snapshot   Exercise `gorm:"type:jsonb;serializer:json"`
+}
+
+// FieldChange is a field that differs between two revisions.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Revision is a revision with the changes to the one before.
+type Revision struct {
+	ExerciseRevision
+	Changes []FieldChange
+}
+
+// revisionFields are the fields compared between revisions, in form order.
+var revisionFields = []struct {
+	Name  string
+	Value func(Exercise) string
+}{
+	{"Name", func(e Exercise) string { return e.Name }},
+	{"Aliases", func(e Exercise) string { return strings.Join(e.Aliases, ", ") }},
+	{"Variation of", func(e Exercise) string { return e.ParentName }},
+	{"Force", func(e Exercise) string { return e.Force.String() }},
+	{"Level", func(e Exercise) string { return e.Level.String() }},
+	{"Mechanic", func(e Exercise) string { return e.Mechanic.String() }},
+	{"Category", func(e Exercise) string { return e.Category.String() }},
+	{"Primary", func(e Exercise) string { return e.PrimaryMuscle.String() }},
+	{"Secondary", func(e Exercise) string { return join(e.SecondaryMuscles, ", ") }},
+	{"Equipment", func(e Exercise) string { return join(e.Equipment, ", ") }},
+	{"Instructions", func(e Exercise) string { return e.Instructions }},
+	{"Images", func(e Exercise) string { return strings.Join(e.Images, ", ") }},
+}
+
+// diffExercises lists the fields changed from old to new, without an old
+// revision every field set in new is listed.
+func diffExercises(old *Exercise, new Exercise) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range revisionFields {
+		before, after := "", field.Value(new)
+		if old != nil {
+			before = field.Value(*old)
+		}
+		if before != after {
+			changes = append(changes, FieldChange{field.Name, before, after})
+		}
+	}
+	return changes
+}
+
+// recordRevision stores the exercise as changed by the user, tx is the
+// transaction of the change.
+func (a *App) recordRevision(tx *gorm.DB, username string, action RevisionAction, exercise Exercise) error {
+	if exercise.Parent != nil {
+		exercise.ParentName = exercise.Parent.Name
+		exercise.Parent = nil
+	}
+	revision := ExerciseRevision{
+		ExerciseID: exercise.ID,
+		Username:   username,
+		Action:     action,
+		Snapshot:   exercise,
+	}
+	return gorm.G[ExerciseRevision](tx).Create(*a.ctx, &revision)
+}
+
+func (a *App) ExerciseHistory(c *gin.Context) {
+	a.renderHistory(c, c.Param("id"), nil)
+}
+
+// RevertExercise restores the fields of an earlier revision as a new
+// revision, later revisions stay in the history.
+func (a *App) RevertExercise(c *gin.Context) {
+	id := c.Param("id")
+	revision, err := gorm.G[ExerciseRevision](a.db).
+		Where("id = ? AND exercise_id = ?", c.Param("revision"), id).
+		First(*a.ctx)
+	if err == nil {
+		err = a.revertExercise(c, revision)
+	}
+	if err != nil {
+		log.Printf("revert error: %v", err)
+		a.renderHistory(c, id, err)
+		return
+	}
+
+	c.Header("HX-Location", `{"path":"/exercise/`+id+`", "target":"#content"}`)
+}
+
+func (a *App) revertExercise(c *gin.Context, revision ExerciseRevision) error {
+	exercise := revision.Snapshot
+	exercise.ID = 0
+	// the catalog can have changed since, like a muscle deleted
+	err := binding.Validator.ValidateStruct(&exercise)
+	if err != nil {
+		return fieldErrors(err, exercise)
+	}
+	err = a.nameTaken(&exercise, revision.ExerciseID)
+	if err == nil {
+		err = a.setParent(&exercise, revision.ExerciseID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		current, err := gorm.G[Exercise](tx).Where("id = ?", revision.ExerciseID).First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		// a revert is an edit, open forms of the exercise are outdated
+		exercise.Version = current.Version + 1
+		rows, err := gorm.G[Exercise](tx).
+			Select("*").
+			Omit("id", "created_at", "trashed_at").
+			Where("id = ? AND version = ?", revision.ExerciseID, current.Version).
+			Updates(*a.ctx, exercise)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			// changed since it was loaded
+			current, err := gorm.G[Exercise](tx).Preload("Parent", nil).Where("id = ?", revision.ExerciseID).First(*a.ctx)
+			if err != nil {
+				return err
+			}
+			return conflictError(current)
+		}
+		exercise.ID = revision.ExerciseID
+		return a.recordRevision(tx, currentUser(c), Reverted, exercise)
+	})
+}
+
+func (a *App) renderHistory(c *gin.Context, id string, err error) {
+	revisions, listErr := a.history(id)
+	if listErr != nil {
+		log.Printf("db error: %v", listErr)
+		err = errors.Join(err, listErr)
+	}
+
+	data := map[string]any{
+		"ID":        id,
+		"Revisions": revisions,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	component := htmx.NewComponent("templates/components/exercise_history.html").SetData(data)
+	a.render(c, &component)
+}
+
+// history returns the revisions of the exercise newest first, each compared
+// to the revision before.
+func (a *App) history(id string) ([]Revision, error) {
+	revisions, err := gorm.G[ExerciseRevision](a.db).
+		Where("exercise_id = ?", id).
+		Order("id").
+		Find(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]Revision, len(revisions))
+	var previous *Exercise
+	for i, revision := range revisions {
+		history[len(revisions)-1-i] = Revision{revision, diffExercises(previous, revision.Snapshot)}
+		previous = &revision.Snapshot
+	}
+	return history, nil
+}
+
+// revisionImages returns the images of all revisions of the exercise, files
+// are kept as long as a revision refers to them.
+func (a *App) revisionImages(tx *gorm.DB, id uint) ([]string, error) {
+	revisions, err := gorm.G[ExerciseRevision](tx).Where("exercise_id = ?", id).Find(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	images := []string{}
+	for _, revision := range revisions {
+		for _, image := range revision.Snapshot.Images {
+			if !slices.Contains(images, image) {
+				images = append(images, image)
+			}
+		}
+	}
+	return images, nil
+}
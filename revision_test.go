@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	revisionCols = []string{"ID", "CreatedAt", "ExerciseID", "Username", "Action", "Snapshot"}
+	revisedAt    = time.Date(2025, 10, 2, 8, 30, 0, 0, time.UTC)
+	snapshot1    = Exercise{
+		ID: 1, Name: "fff", Force: Push, Level: Easy, Mechanic: Compound, Category: Endurance,
+		PrimaryMuscle: "Abdominals", SecondaryMuscles: []Muscle{"Chest"},
+		Equipment: []Equipment{"Bench"}, Instructions: "asf", Images: []string{"fff_old_0"},
+	}
+	snapshot2 = Exercise{
+		ID: 1, Name: "fff", Aliases: []string{"f"}, ParentName: "bla", Force: Pull, Level: Easy,
+		Mechanic: Compound, Category: Endurance, PrimaryMuscle: "Abdominals", SecondaryMuscles: []Muscle{"Chest"}, Equipment: []Equipment{"Bench"}, Instructions: "asf",
+		Images: []string{"fff_0", "fff_1"},
+	}
+	revision1 = []driver.Value{1, revisedAt, 1, "alice", "created", snapshotJSON(snapshot1)}
+	revision2 = []driver.Value{2, revisedAt, 1, "bob", "updated", snapshotJSON(snapshot2)}
+)
+
+func snapshotJSON(exercise Exercise) string {
+	data, _ := json.Marshal(exercise)
+	return string(data)
+}
+
+// expectRevision expects the revision recorded for a change of the exercise.
+func expectRevision(id uint, action RevisionAction) {
+	mocksql.ExpectQuery(`INSERT INTO "exercise_revisions" ("created_at","exercise_id","username","action","snapshot") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), id, sqlmock.AnyArg(), string(action), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// uploadedImage matches the path of an uploaded image of the exercise, those
+// are named uniquely per upload.
+func uploadedImage(id uint, idx int) func(string) bool {
+	return func(path string) bool {
+		return strings.HasPrefix(path, "./static/images/"+strconv.FormatUint(uint64(id), 10)+"_") &&
+			strings.HasSuffix(path, "_"+strconv.Itoa(idx))
+	}
+}
+
+func TestDiffExercises(t *testing.T) {
+	assert.Equal(t, []FieldChange{
+		{"Aliases", "", "f"},
+		{"Variation of", "", "bla"},
+		{"Force", "Push", "Pull"},
+		{"Images", "fff_old_0", "fff_0, fff_1"},
+	}, diffExercises(&snapshot1, snapshot2))
+	assert.Empty(t, diffExercises(&snapshot2, snapshot2))
+	assert.Equal(t, []FieldChange{
+		{"Name", "", "fff"},
+		{"Force", "", "Push"},
+		{"Level", "", "Easy"},
+		{"Mechanic", "", "Compound"},
+		{"Category", "", "Endurance"},
+		{"Primary", "", "Abdominals"},
+		{"Secondary", "", "Chest"},
+		{"Equipment", "", "Bench"},
+		{"Instructions", "", "asf"},
+		{"Images", "", "fff_old_0"},
+	}, diffExercises(nil, snapshot1))
+}
+
+func TestExerciseHistory(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		method  string
+		url     string
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"GET", "/exercise/1/history",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1 ORDER BY id`).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...).AddRow(revision2...))
+			},
+			"./fixtures/revision/history.html",
+		},
+		{
+			"POST", "/exercise/1/revert/1",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE id = $1 AND exercise_id = $2 ORDER BY "exercise_revisions"."id" LIMIT $3`).
+					WithArgs("1", "1", 1).
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"fff"}`, `{"fff"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1 ORDER BY id`).
+					WithArgs("1").
+					WillReturnError(fmt.Errorf("test history error"))
+			},
+			"./fixtures/revision/revert_error.html",
+		},
+		{
+			"POST", "/exercise/1/revert/3",
+			func() {
+				invalid := snapshot1
+				invalid.PrimaryMuscle = "Delts"
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE id = $1 AND exercise_id = $2 ORDER BY "exercise_revisions"."id" LIMIT $3`).
+					WithArgs("3", "1", 1).
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(3, revisedAt, 1, "alice", "updated", snapshotJSON(invalid)))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1 ORDER BY id`).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...))
+			},
+			"./fixtures/revision/revert_invalid.html",
+		},
+		{
+			"POST", "/exercise/1/revert/1",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE id = $1 AND exercise_id = $2 ORDER BY "exercise_revisions"."id" LIMIT $3`).
+					WithArgs("1", "1", 1).
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+					WithArgs(`{"fff"}`, `{"fff"}`, 1, 1).
+					WillReturnRows(sqlmock.NewRows(exCols))
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(append(exCols, "Version")).AddRow(append(ex1, 3)...))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+					WithArgs(sqlmock.AnyArg(), 4, "fff", nil, nil, "Push", "Easy", "Compound", "Endurance", "Abdominals", `["Chest"]`, `["Bench"]`, "asf", `["fff_old_0"]`, 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(append(exCols, "Version")).AddRow(append(ex1, 4)...))
+				mocksql.ExpectRollback()
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1 ORDER BY id`).
+					WithArgs("1").
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...))
+			},
+			"./fixtures/revision/revert_conflict.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestRevertExercise(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE id = $1 AND exercise_id = $2 ORDER BY "exercise_revisions"."id" LIMIT $3`).
+		WithArgs("1", "1", 1).
+		WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE (LOWER(name) = ANY($1) OR EXISTS ( SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2) )) AND id <> $3 LIMIT $4`).
+		WithArgs(`{"fff"}`, `{"fff"}`, 1, 1).
+		WillReturnRows(sqlmock.NewRows(exCols))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(append(exCols, "Version")).AddRow(append(ex1, 3)...))
+	mocksql.ExpectExec(`UPDATE "exercises" SET "updated_at"=$1,"version"=$2,"name"=$3,"aliases"=$4,"parent_id"=$5,"force"=$6,"level"=$7,"mechanic"=$8,"category"=$9,"primary_muscle"=$10,"secondary_muscles"=$11,"equipment"=$12,"instructions"=$13,"images"=$14 WHERE id = $15 AND version = $16`).
+		WithArgs(sqlmock.AnyArg(), 4, "fff", nil, nil, "Push", "Easy", "Compound", "Endurance", "Abdominals", `["Chest"]`, `["Bench"]`, "asf", `["fff_old_0"]`, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(1, Reverted)
+	mocksql.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/exercise/1/revert/1", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Remote-User", "alice")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"path":"/exercise/1", "target":"#content"}`, w.Result().Header.Get("HX-Location"))
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql"
+	"maps"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// minimum pg_trgm word similarity for a name to count as a typo match
+	searchSimilarity = 0.3
+	searchDocument   = `to_tsvector('english', name || ' ' || COALESCE(aliases::text, '') || ' ' || instructions)`
+	searchQuery      = `websearch_to_tsquery('english', @search)`
+)
+
+// searchExercises restricts query to exercises matching search and selects a
+// "rank" column to order by. Postgres uses full-text search over name, aliases
+// and instructions combined with trigram similarity on the name to tolerate typos.
+// Other backends fall back to case-insensitive matching of every search term.
+func searchExercises(db *gorm.DB, query gorm.ChainInterface[Exercise], search string) gorm.ChainInterface[Exercise] {
+	search = strings.TrimSpace(search)
+	if search == "" {
+		return query
+	}
+
+	if db.Dialector.Name() == "postgres" {
+		args := []any{sql.Named("search", search), sql.Named("similarity", searchSimilarity)}
+		return query.
+			Select(`*, ts_rank(`+searchDocument+`, `+searchQuery+`) + word_similarity(@search, name) AS rank`, args...).
+			Where(`(`+searchDocument+` @@ `+searchQuery+`
+				OR word_similarity(@search, name) > @similarity
+				OR name ILIKE '%' || @search || '%'
+				OR aliases::text ILIKE '%' || @search || '%')`, args...)
+	}
+
+	for _, term := range strings.Fields(strings.ToLower(search)) {
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(aliases) LIKE ? OR LOWER(instructions) LIKE ?",
+			"%"+term+"%", "%"+term+"%", "%"+term+"%")
+	}
+	return query.Select("*, CASE WHEN LOWER(name) LIKE ? THEN 1 ELSE 0 END AS rank", "%"+strings.ToLower(search)+"%")
+}
+
+// searchSortColumns returns the sortable columns for a search and defaults to
+// ordering by relevance when the user did not pick a column.
+func searchSortColumns(p *Pagination, search string) map[string]string {
+	if strings.TrimSpace(search) == "" {
+		return exerciseSortColumns
+	}
+
+	sortable := maps.Clone(exerciseSortColumns)
+	sortable["Relevance"] = "rank"
+	if p.Sort == "" {
+		p.Sort = "Relevance"
+		p.Dir = "desc"
+	}
+	return sortable
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// otherDialector is Postgres under another name, to reach the search
+// fallback of the other backends.
+type otherDialector struct {
+	gorm.Dialector
+}
+
+func (otherDialector) Name() string {
+	return "sqlite"
+}
+
+func TestSearchExercisesFallback(t *testing.T) {
+	mockDb, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	db, err := gorm.Open(otherDialector{postgres.New(postgres.Config{
+		Conn:       mockDb,
+		DriverName: "postgres",
+	})}, &gorm.Config{})
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT *, CASE WHEN LOWER(name) LIKE $1 THEN 1 ELSE 0 END AS rank FROM "exercises" `+
+		`WHERE trashed_at IS NULL AND (LOWER(name) LIKE $2 OR LOWER(aliases) LIKE $3 OR LOWER(instructions) LIKE $4) `+
+		`AND (LOWER(name) LIKE $5 OR LOWER(aliases) LIKE $6 OR LOWER(instructions) LIKE $7)`).
+		WithArgs("%bench press%", "%bench%", "%bench%", "%bench%", "%press%", "%press%", "%press%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bench Press"))
+	exercises, err := searchExercises(db, gorm.G[Exercise](db).Where("trashed_at IS NULL"), " Bench PRESS ").Find(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, exercises, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// SetType is how the units of a set are performed.
+type SetType = Enum[setTypeNames]
+
+const (
+	StraightSet SetType = "Straight"
+	Superset    SetType = "Superset"
+	GiantSet    SetType = "Giant set"
+	Circuit     SetType = "Circuit"
+	DropSet     SetType = "Drop set"
+	AMRAP       SetType = "AMRAP"
+	EMOM        SetType = "EMOM"
+	TimedSet    SetType = "Timed"
+)
+
+type setTypeNames struct{}
+
+func (setTypeNames) names() []string {
+	return []string{"Straight", "Superset", "Giant set", "Circuit", "Drop set", "AMRAP", "EMOM", "Timed"}
+}
+
+// maxRounds and maxSeconds limit the rounds and times of a set, the timer
+// goes through all of them.
+const (
+	maxRounds  = 100
+	maxSeconds = 3600
+)
+
+// SetOptions are the type of a set and the rounds and times it is performed
+// with, times are in seconds.
+type SetOptions struct {
+	Type SetType `form:"type,default=Straight" binding:"enum" gorm:"not null;default:Straight"`
+	// Rounds of a circuit, EMOM or timed set, the units of the other types
+	// are done for their target sets
+	Rounds int `form:"rounds" binding:"gte=0,lte=100"`
+	// Rest follows every set, in alternating sets every round and in drop
+	// sets the last drop
+	Rest int `form:"rest" binding:"gte=0,lte=3600"`
+	// Work is the time cap of an AMRAP, the interval of an EMOM and the
+	// work of a timed set
+	Work int `form:"work" binding:"gte=0,lte=3600"`
+}
+
+// validate checks the rounds and times needed by the type and clears the
+// ones it does not use.
+func (o *SetOptions) validate() error {
+	if o.Rounds > maxRounds {
+		return fmt.Errorf("a set has at most %d rounds", maxRounds)
+	}
+	if o.Rest > maxSeconds || o.Work > maxSeconds {
+		return fmt.Errorf("a set has at most %s of work and rest", formatSeconds(maxSeconds))
+	}
+	switch o.Type {
+	case StraightSet, Superset, GiantSet, DropSet:
+		o.Rounds, o.Work = 0, 0
+	case Circuit:
+		o.Work = 0
+		if o.Rounds <= 0 {
+			return errors.New("a circuit needs the number of rounds")
+		}
+	case AMRAP:
+		o.Rounds = 0
+		if o.Work <= 0 {
+			return errors.New("an AMRAP needs a time cap")
+		}
+	case EMOM:
+		// the rest is what is left of the interval
+		o.Rest = 0
+		if o.Rounds <= 0 || o.Work <= 0 {
+			return errors.New("an EMOM needs the number of rounds and the interval")
+		}
+	case TimedSet:
+		if o.Rounds <= 0 || o.Work <= 0 {
+			return errors.New("a timed set needs the number of rounds and the work time")
+		}
+	}
+	return nil
+}
+
+// Alternates tells whether the units are done in turn, one set of each per
+// round, instead of all sets of a unit before the next one.
+func (o SetOptions) Alternates() bool {
+	switch o.Type {
+	case Superset, GiantSet, Circuit, AMRAP, EMOM, TimedSet:
+		return true
+	}
+	return false
+}
+
+// Drops tells whether the sets follow each other without rest at a lower
+// weight.
+func (o SetOptions) Drops() bool {
+	return o.Type == DropSet
+}
+
+// Describe explains how the set is performed.
+func (o SetOptions) Describe() string {
+	rest := func(after string) string {
+		if o.Rest == 0 {
+			return ""
+		}
+		return ", rest " + formatSeconds(o.Rest) + after
+	}
+	switch o.Type {
+	case Superset, GiantSet:
+		return "alternate the exercises" + rest(" after each round")
+	case Circuit:
+		return fmt.Sprintf("%d rounds of the exercises in turn%s", o.Rounds, rest(" after each round"))
+	case DropSet:
+		return "lower the weight after each set without rest" + rest(" after the last drop")
+	case AMRAP:
+		return "as many rounds as possible in " + formatSeconds(o.Work) + rest(" afterwards")
+	case EMOM:
+		return fmt.Sprintf("%d rounds, one exercise every %s in turn", o.Rounds, formatSeconds(o.Work))
+	case TimedSet:
+		return fmt.Sprintf("%d rounds of %s work%s", o.Rounds, formatSeconds(o.Work), rest(""))
+	}
+	if o.Rest == 0 {
+		return ""
+	}
+	return "rest " + formatSeconds(o.Rest) + " between sets"
+}
+
+// Issue describes a number of units not matching the type, empty if it
+// matches.
+func (s Set) Issue() string {
+	units := len(s.Units)
+	switch {
+	case s.Type == StraightSet && units > 1:
+		return "a straight set has a single exercise"
+	case s.Type == DropSet && units > 1:
+		return "a drop set has a single exercise"
+	case s.Type == Superset && units != 2:
+		return "a superset pairs two exercises"
+	case s.Type == GiantSet && units < 3:
+		return "a giant set has three or more exercises"
+	case s.Type == Circuit && units < 2:
+		return "a circuit has two or more exercises"
+	}
+	return ""
+}
+
+// formatSeconds formats seconds as minutes and seconds from a minute on.
+func formatSeconds(seconds int) string {
+	if seconds < 60 {
+		return strconv.Itoa(seconds) + " s"
+	}
+	return fmt.Sprintf("%d:%02d min", seconds/60, seconds%60)
+}
+
+// WorkoutGroup is the units of a workout performed as one set of the plan.
+type WorkoutGroup struct {
+	Set   Set
+	Units []WorkoutUnit
+}
+
+// Groups groups the units by the set of the plan they come from, units
+// without one are straight sets on their own.
+func (w Workout) Groups() []WorkoutGroup {
+	sets := map[uint]Set{}
+	for _, set := range w.plannedSets() {
+		sets[set.ID] = set
+	}
+	groups := []WorkoutGroup{}
+	for _, unit := range w.Units {
+		set := Set{SetOptions: SetOptions{Type: StraightSet}}
+		if planned := w.plannedUnit(unit); planned != nil {
+			if plannedSet, ok := sets[planned.SetID]; ok {
+				set = plannedSet
+			}
+		}
+		last := len(groups) - 1
+		if set.ID != 0 && last >= 0 && groups[last].Set.ID == set.ID {
+			groups[last].Units = append(groups[last].Units, unit)
+			continue
+		}
+		groups = append(groups, WorkoutGroup{Set: set, Units: []WorkoutUnit{unit}})
+	}
+	return groups
+}
+
+// Rounds returns the rounds done, a round is complete with a set of every
+// unit.
+func (g WorkoutGroup) Rounds() int {
+	rounds := -1
+	for _, unit := range g.Units {
+		if rounds < 0 || len(unit.Sets) < rounds {
+			rounds = len(unit.Sets)
+		}
+	}
+	return max(rounds, 0)
+}
+
+// Next returns the id of the unit whose turn it is in an alternating set, 0
+// for other sets.
+func (g WorkoutGroup) Next() uint {
+	if !g.Set.Alternates() || len(g.Units) < 2 {
+		return 0
+	}
+	rounds := g.Rounds()
+	for _, unit := range g.Units {
+		if len(unit.Sets) == rounds {
+			return unit.ID
+		}
+	}
+	return 0
+}
+
+// SaveSet sets the type, rounds and times of a set of the plan.
+func (a *App) SaveSet(c *gin.Context) {
+	var options SetOptions
+	id := c.Param("id")
+	err := c.ShouldBindWith(&options, binding.Form)
+	if err == nil {
+		err = options.validate()
+	}
+	if err == nil {
+		_, err = gorm.G[Set](a.db).
+			Where("id = ? AND plan_id = ?", c.Param("set"), id).
+			Select("type", "rounds", "rest", "work").
+			Updates(*a.ctx, Set{SetOptions: options})
+	}
+	if err != nil {
+		log.Printf("set error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+// JoinUnit moves the unit to the end of the set before its own, joining
+// exercises into supersets and circuits.
+func (a *App) JoinUnit(c *gin.Context) {
+	id := c.Param("id")
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		unit, set, err := a.loadPlanUnit(tx, id, c.Param("unit"))
+		if err != nil {
+			return err
+		}
+		previous, err := gorm.G[Set](tx).
+			Where("plan_id = ? AND position < ?", id, set.Position).
+			Order("position DESC").
+			First(*a.ctx)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("the first set cannot be joined")
+		}
+		if err != nil {
+			return err
+		}
+		position, err := a.nextPosition(tx, "units", "set_id = ?", previous.ID)
+		if err != nil {
+			return err
+		}
+		_, err = gorm.G[Unit](tx).
+			Where("id = ?", unit.ID).
+			Select("set_id", "position").
+			Updates(*a.ctx, Unit{SetID: previous.ID, Position: position})
+		if err != nil {
+			return err
+		}
+		return a.deleteEmptySets(tx, id)
+	})
+	if err != nil {
+		log.Printf("join error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+// SplitUnit moves the unit out of its set into a straight set right after
+// it.
+func (a *App) SplitUnit(c *gin.Context) {
+	id := c.Param("id")
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		unit, set, err := a.loadPlanUnit(tx, id, c.Param("unit"))
+		if err != nil {
+			return err
+		}
+		count, err := gorm.G[Unit](tx).Where("set_id = ?", set.ID).Count(*a.ctx, "id")
+		if err != nil || count < 2 {
+			return err
+		}
+		err = tx.WithContext(*a.ctx).Model(&Set{}).
+			Where("plan_id = ? AND position > ?", id, set.Position).
+			Update("position", gorm.Expr("position + 1")).Error
+		if err != nil {
+			return err
+		}
+		split := Set{
+			PlanID:     set.PlanID,
+			Position:   set.Position + 1,
+			SetOptions: SetOptions{Type: StraightSet},
+		}
+		err = gorm.G[Set](tx).Create(*a.ctx, &split)
+		if err != nil {
+			return err
+		}
+		_, err = gorm.G[Unit](tx).
+			Where("id = ?", unit.ID).
+			Select("set_id", "position").
+			Updates(*a.ctx, Unit{SetID: split.ID, Position: 0})
+		return err
+	})
+	if err != nil {
+		log.Printf("split error: %v", err)
+	}
+	a.renderUnits(c, id, err)
+}
+
+// loadPlanUnit loads a unit of the plan with its set.
+func (a *App) loadPlanUnit(tx *gorm.DB, plan, id string) (Unit, Set, error) {
+	unit, err := gorm.G[Unit](tx).
+		Where("id = ? AND set_id IN (SELECT id FROM sets WHERE plan_id = ?)", id, plan).
+		First(*a.ctx)
+	if err != nil {
+		return unit, Set{}, err
+	}
+	set, err := gorm.G[Set](tx).Where("id = ?", unit.SetID).First(*a.ctx)
+	return unit, set, err
+}
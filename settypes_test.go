@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOptionsValidate(t *testing.T) {
+	options := SetOptions{Type: Superset, Rounds: 3, Rest: 90, Work: 40}
+	assert.NoError(t, options.validate())
+	assert.Equal(t, SetOptions{Type: Superset, Rest: 90}, options)
+
+	options = SetOptions{Type: Circuit}
+	assert.EqualError(t, options.validate(), "a circuit needs the number of rounds")
+	options = SetOptions{Type: AMRAP, Rounds: 2}
+	assert.EqualError(t, options.validate(), "an AMRAP needs a time cap")
+	options = SetOptions{Type: EMOM, Rounds: 10}
+	assert.EqualError(t, options.validate(), "an EMOM needs the number of rounds and the interval")
+	options = SetOptions{Type: TimedSet, Work: 40}
+	assert.EqualError(t, options.validate(), "a timed set needs the number of rounds and the work time")
+
+	options = SetOptions{Type: EMOM, Rounds: -3, Work: 60}
+	assert.EqualError(t, options.validate(), "an EMOM needs the number of rounds and the interval")
+	options = SetOptions{Type: Circuit, Rounds: 100000}
+	assert.EqualError(t, options.validate(), "a set has at most 100 rounds")
+	options = SetOptions{Type: TimedSet, Rounds: 8, Rest: 10, Work: 3601}
+	assert.EqualError(t, options.validate(), "a set has at most 60:00 min of work and rest")
+	options = SetOptions{Type: StraightSet, Rest: 7200}
+	assert.EqualError(t, options.validate(), "a set has at most 60:00 min of work and rest")
+
+	options = SetOptions{Type: EMOM, Rounds: 10, Rest: 30, Work: 60}
+	assert.NoError(t, options.validate())
+	assert.Equal(t, 0, options.Rest)
+}
+
+func TestSetOptionsDescribe(t *testing.T) {
+	tests := []struct {
+		options  SetOptions
+		describe string
+	}{
+		{SetOptions{Type: StraightSet}, ""},
+		{SetOptions{Type: StraightSet, Rest: 90}, "rest 1:30 min between sets"},
+		{SetOptions{Type: Superset, Rest: 60}, "alternate the exercises, rest 1:00 min after each round"},
+		{SetOptions{Type: GiantSet}, "alternate the exercises"},
+		{SetOptions{Type: Circuit, Rounds: 3}, "3 rounds of the exercises in turn"},
+		{SetOptions{Type: DropSet, Rest: 120}, "lower the weight after each set without rest, rest 2:00 min after the last drop"},
+		{SetOptions{Type: AMRAP, Work: 600}, "as many rounds as possible in 10:00 min"},
+		{SetOptions{Type: EMOM, Rounds: 10, Work: 60}, "10 rounds, one exercise every 1:00 min in turn"},
+		{SetOptions{Type: TimedSet, Rounds: 8, Work: 20, Rest: 10}, "8 rounds of 20 s work, rest 10 s"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.describe, test.options.Describe())
+	}
+}
+
+func TestSetIssue(t *testing.T) {
+	units := func(n int) []Unit { return make([]Unit, n) }
+	assert.Equal(t, "", Set{SetOptions: SetOptions{Type: StraightSet}, Units: units(1)}.Issue())
+	assert.Equal(t, "a straight set has a single exercise", Set{SetOptions: SetOptions{Type: StraightSet}, Units: units(2)}.Issue())
+	assert.Equal(t, "a drop set has a single exercise", Set{SetOptions: SetOptions{Type: DropSet}, Units: units(2)}.Issue())
+	assert.Equal(t, "a superset pairs two exercises", Set{SetOptions: SetOptions{Type: Superset}, Units: units(3)}.Issue())
+	assert.Equal(t, "a giant set has three or more exercises", Set{SetOptions: SetOptions{Type: GiantSet}, Units: units(2)}.Issue())
+	assert.Equal(t, "a circuit has two or more exercises", Set{SetOptions: SetOptions{Type: Circuit}, Units: units(1)}.Issue())
+	assert.Equal(t, "", Set{SetOptions: SetOptions{Type: EMOM}, Units: units(1)}.Issue())
+}
+
+func TestWorkoutGroups(t *testing.T) {
+	superset := Set{ID: 4, SetOptions: SetOptions{Type: Superset}}
+	straight := Set{ID: 5, SetOptions: SetOptions{Type: StraightSet}}
+	workout := Workout{
+		Plan: &Plan{Sets: []Set{superset, straight}},
+		Units: []WorkoutUnit{
+			{ID: 1, Unit: &Unit{SetID: 4}, Sets: []LoggedSet{{}, {}}},
+			{ID: 2, Unit: &Unit{SetID: 4}, Sets: []LoggedSet{{}}},
+			{ID: 3, Unit: &Unit{SetID: 5}},
+			{ID: 4},
+			{ID: 5},
+		},
+	}
+	groups := workout.Groups()
+	assert.Len(t, groups, 4)
+	assert.Equal(t, superset, groups[0].Set)
+	assert.Len(t, groups[0].Units, 2)
+	assert.Equal(t, 1, groups[0].Rounds())
+	assert.Equal(t, uint(2), groups[0].Next())
+	assert.Equal(t, uint(0), groups[1].Next())
+	assert.Equal(t, StraightSet, groups[2].Set.Type)
+	assert.Equal(t, []WorkoutUnit{{ID: 5}}, groups[3].Units)
+
+	workout.Units[1].Sets = append(workout.Units[1].Sets, LoggedSet{})
+	assert.Equal(t, uint(1), workout.Groups()[0].Next())
+}
+
+func TestPlanSets(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	expectPlanUnit := func() {
+		mocksql.ExpectQuery(`SELECT * FROM "units" WHERE id = $1 AND set_id IN (SELECT id FROM sets WHERE plan_id = $2) ORDER BY "units"."id" LIMIT $3`).
+			WithArgs("8", "1", 1).
+			WillReturnRows(sqlmock.NewRows(unitCols).AddRow(8, 5, 0, 3, 0, 0, 0, 0.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0))
+		mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE id = $1 ORDER BY "sets"."id" LIMIT $2`).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows(setCols).AddRow(5, 1, 1, "Straight", 0, 0, 0))
+	}
+	tests := []struct {
+		url     string
+		form    url.Values
+		fixture string
+		expect  func()
+	}{
+		{"/plan/1/set/4", url.Values{"type": {"Circuit"}, "rounds": {"3"}, "rest": {"120"}, "work": {"30"}}, "set_saved.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`UPDATE "sets" SET "type"=$1,"rounds"=$2,"rest"=$3,"work"=$4 WHERE id = $5 AND plan_id = $6`).
+				WithArgs("Circuit", 3, 120, 0, "4", "1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+		{"/plan/1/set/4", url.Values{"type": {"EMOM"}}, "set_invalid.html", func() {}},
+		{"/plan/1/unit/8/join", nil, "join.html", func() {
+			mocksql.ExpectBegin()
+			expectPlanUnit()
+			mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE plan_id = $1 AND position < $2 ORDER BY position DESC,"sets"."id" LIMIT $3`).
+				WithArgs("1", 1, 1).
+				WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Superset", 0, 90, 0))
+			// a unit at 2 after deleting the one at 1, the joined one follows it
+			mocksql.ExpectQuery(`SELECT COALESCE(MAX(position), -1) + 1 FROM "units" WHERE set_id = $1`).
+				WithArgs(4).
+				WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(3))
+			mocksql.ExpectExec(`UPDATE "units" SET "set_id"=$1,"position"=$2 WHERE id = $3`).
+				WithArgs(4, 3, 8).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectExec(`DELETE FROM "sets" WHERE plan_id = $1 AND NOT EXISTS (SELECT 1 FROM units WHERE units.set_id = sets.id)`).
+				WithArgs("1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+		{"/plan/1/unit/8/join", nil, "join_first.html", func() {
+			mocksql.ExpectBegin()
+			expectPlanUnit()
+			mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE plan_id = $1 AND position < $2 ORDER BY position DESC,"sets"."id" LIMIT $3`).
+				WithArgs("1", 1, 1).
+				WillReturnRows(sqlmock.NewRows(setCols))
+			mocksql.ExpectRollback()
+		}},
+		{"/plan/1/unit/8/split", nil, "split.html", func() {
+			mocksql.ExpectBegin()
+			expectPlanUnit()
+			mocksql.ExpectQuery(`SELECT COUNT("id") FROM "units" WHERE set_id = $1`).
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mocksql.ExpectExec(`UPDATE "sets" SET "position"=position + 1 WHERE plan_id = $1 AND position > $2`).
+				WithArgs("1", 1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+				WithArgs(1, 2, "Straight", 0, 0, 0).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+			mocksql.ExpectExec(`UPDATE "units" SET "set_id"=$1,"position"=$2 WHERE id = $3`).
+				WithArgs(6, 0, 8).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", test.url, strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			test.expect()
+			expectLoadPlan()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, filepath.Join("./fixtures/settypes", test.fixture), w)
+			if err := mocksql.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadSupersetWorkout(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/workout/5", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, 1, nil, 100.0))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Superset", 0, 90, 0).AddRow(5, 1, 1, "Drop set", 0, 120, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(1, 5, 7, 0, 2).AddRow(2, 5, 8, 1, 1).AddRow(3, 5, 9, 2, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" IN ($1,$2)`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...).AddRow(ex1...))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" IN ($1,$2,$3) ORDER BY id`).
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "WorkoutUnitID", "ExerciseID", "Reps", "Weight", "Drop"}).
+			AddRow(1, 1, 2, 8, 60.0, false).
+			AddRow(2, 3, 1, 10, 20.0, false).
+			AddRow(3, 3, 1, 8, 15.0, true))
+	mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE "personal_records"."logged_set_id" IN ($1,$2,$3) ORDER BY id`).
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows(recordCols))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."id" IN ($1,$2,$3)`).
+		WithArgs(7, 8, 9).
+		WillReturnRows(sqlmock.NewRows(unitCols).
+			AddRow(7, 4, 0, 2, 0, 3, 8, 60.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0).
+			AddRow(8, 4, 1, 1, 0, 3, 12, 0.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0).
+			AddRow(9, 5, 0, 1, 0, 0, 0, 0.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/settypes/workout_superset.html", w)
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
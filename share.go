@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReadSharedPlan shows a shared plan read-only, the link works without
+// logging in.
+func (a *App) ReadSharedPlan(c *gin.Context) {
+	token := c.Param("token")
+	plan, err := a.loadPlanWhere("share_token = ?", token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderSharedPlan(c, plan, token, err)
+}
+
+func (a *App) renderSharedPlan(c *gin.Context, plan Plan, token string, err error) {
+	data := map[string]any{
+		"Plan":  plan,
+		"Token": token,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/shared_plan.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// ExportSharedPlan downloads a shared plan as a plan file.
+func (a *App) ExportSharedPlan(c *gin.Context) {
+	plan, err := a.loadPlanWhere("share_token = ?", c.Param("token"))
+	a.exportPlan(c, plan, err)
+}
+
+// CopySharedPlan copies a shared plan to the plans of the logged in user and
+// opens the copy. Unlike the shared plan itself the route is behind the
+// login, visitors are asked to log in first.
+func (a *App) CopySharedPlan(c *gin.Context) {
+	plan, err := a.loadPlanWhere("share_token = ?", c.Param("token"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	// the shared plan is shown again on errors, the plans of the owner are
+	// not for visitors
+	err = a.clonePlan(c, plan, err)
+	if err != nil {
+		a.renderSharedPlan(c, plan, c.Param("token"), err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var sharedPlanCols = []string{"ID", "CreatedAt", "UpdatedAt", "Name", "Description", "Template", "ShareToken"}
+
+func TestSharePlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		method  string
+		fixture string
+		expect  func()
+		token   any
+	}{
+		{"POST", "share.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`UPDATE "plans" SET "share_token"=$1,"updated_at"=$2 WHERE id = $3 AND share_token IS NULL`).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}, "0123abcd"},
+		{"DELETE", "unshare.html", func() {
+			mocksql.ExpectBegin()
+			mocksql.ExpectExec(`UPDATE "plans" SET "share_token"=$1,"updated_at"=$2 WHERE id = $3`).
+				WithArgs(nil, sqlmock.AnyArg(), "1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mocksql.ExpectCommit()
+		}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(test.method, "/plan/1/share", nil)
+			req.Header.Set("HX-Request", "true")
+			req.Host = "workout.example"
+			test.expect()
+			mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE id = $1 ORDER BY "plans"."id" LIMIT $2`).
+				WithArgs("1", 1).
+				WillReturnRows(sqlmock.NewRows(sharedPlanCols).AddRow(1, t1, t1, "Push day", "", false, test.token))
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, "./fixtures/plan/"+test.fixture, w)
+		})
+	}
+}
+
+// expectSharedPlan expects the queries of the plan shared with the token.
+func expectSharedPlan(token string, found bool) {
+	rows := sqlmock.NewRows(sharedPlanCols)
+	if found {
+		rows.AddRow(1, t1, t1, "Push day", "Chest and triceps", false, token)
+	}
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE share_token = $1 ORDER BY "plans"."id" LIMIT $2`).
+		WithArgs(token, 1).
+		WillReturnRows(rows)
+	if !found {
+		return
+	}
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1 ORDER BY position, id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 90, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."set_id" = $1 ORDER BY position, id`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+}
+
+func TestReadSharedPlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/shared/0123abcd", nil)
+	expectSharedPlan("0123abcd", true)
+	router.ServeHTTP(w, req)
+	validateFixture(t, "./fixtures/plan/shared.html", w)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/shared/unknown", nil)
+	expectSharedPlan("unknown", false)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/shared/unknown/export", nil)
+	expectSharedPlan("unknown", false)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCopySharedPlan(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/plan/copy/0123abcd", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Remote-User", "bob")
+	expectSharedPlan("0123abcd", true)
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Push day (copy)", "Chest and triceps", false, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mocksql.ExpectQuery(`INSERT INTO "sets" ("plan_id","position","type","rounds","rest","work") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("id") DO UPDATE SET "plan_id"="excluded"."plan_id" RETURNING "id"`).
+		WithArgs(2, 0, "Straight", 0, 90, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "units" ("set_id","position","exercise_id","pause","target_sets","target_reps","target_weight","target_progression","target_increment","target_min_reps","target_max_reps","target_training_max","target_percentage","target_rpe") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) ON CONFLICT ("id") DO UPDATE SET "set_id"="excluded"."set_id" RETURNING "id"`).
+		WithArgs(5, 0, 2, 0, 3, 5, 60.0, LinearProgression, 2.5, 0, 0, 0.0, 0.0, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mocksql.ExpectCommit()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, `{"path":"/plan/2", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+
+	// a failed copy shows the shared plan again, not the plans of the owner
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/plan/copy/0123abcd", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Remote-User", "bob")
+	expectSharedPlan("0123abcd", true)
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`INSERT INTO "plans" ("created_at","updated_at","name","description","template","share_token") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Push day (copy)", "Chest and triceps", false, nil).
+		WillReturnError(errors.New("connection lost"))
+	mocksql.ExpectRollback()
+	router.ServeHTTP(w, req)
+	validateFixture(t, "./fixtures/plan/copy_failed.html", w)
+
+	// a revoked link shows nothing, not the plans of the user
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/plan/copy/unknown", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Remote-User", "bob")
+	expectSharedPlan("unknown", false)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Body.String())
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
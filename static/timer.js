@@ -0,0 +1,51 @@
+// startTimer runs the timer of a timed set streamed by the server, playing a
+// high beep when work starts, a low one for rest and short ones counting
+// down. The workout is reloaded when the timer is done.
+function startTimer(button) {
+  const timer = button.closest(".timer");
+  const display = timer.querySelector(".display");
+  const audio = new AudioContext();
+  const beep = (frequency, seconds) => {
+    const oscillator = audio.createOscillator();
+    oscillator.frequency.value = frequency;
+    oscillator.connect(audio.destination);
+    oscillator.start();
+    oscillator.stop(audio.currentTime + seconds);
+  };
+  const cues = {
+    work: () => beep(880, 0.4),
+    rest: () => beep(440, 0.4),
+    countdown: () => beep(660, 0.1),
+  };
+
+  button.disabled = true;
+  const source = new EventSource(timer.dataset.src);
+  const stop = () => {
+    source.close();
+    button.disabled = false;
+  };
+  source.addEventListener("tick", (event) => {
+    const tick = JSON.parse(event.data);
+    const minutes = Math.floor(tick.remaining / 60);
+    const seconds = String(tick.remaining % 60).padStart(2, "0");
+    display.textContent = `${tick.phase} ${tick.exercise} round ${tick.round}/${tick.rounds} ${minutes}:${seconds}`;
+    cues[tick.cue]?.();
+  });
+  source.addEventListener("logged", (event) => {
+    display.textContent = `logged ${event.data}`;
+  });
+  source.addEventListener("failure", (event) => {
+    display.textContent = event.data;
+    stop();
+  });
+  source.addEventListener("done", () => {
+    beep(880, 0.8);
+    stop();
+    htmx.ajax("GET", timer.dataset.workout, "#content");
+  });
+  // the stream is not resumed, a reconnect would restart the timer
+  source.onerror = () => {
+    display.textContent = "timer disconnected";
+    stop();
+  };
+}
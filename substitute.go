@@ -0,0 +1,169 @@
+package main
+
+import (
+	"cmp"
+	"log"
+	"slices"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const maxSubstitutes = 10
+
+// weights of the criteria a substitute is ranked by
+const (
+	samePrimaryScore      = 5
+	primaryAsSecondary    = 2
+	secondaryOverlapScore = 3
+	sameMechanicScore     = 1
+	sameForceScore        = 1
+)
+
+// Substitute is an exercise that can replace another one, a higher score
+// means a better replacement.
+type Substitute struct {
+	Exercise
+	Score float64
+}
+
+// rankSubstitutes scores the candidates as replacement for exercise and
+// returns the best ones. Candidates needing equipment that is not in
+// available are dropped, a nil available allows any equipment.
+func rankSubstitutes(exercise Exercise, candidates []Exercise, available []Equipment) []Substitute {
+	substitutes := []Substitute{}
+	for _, candidate := range candidates {
+		if candidate.ID == exercise.ID || !usable(candidate, available) {
+			continue
+		}
+
+		score := 0.0
+		if candidate.PrimaryMuscle == exercise.PrimaryMuscle {
+			score += samePrimaryScore
+		} else if slices.Contains(exercise.SecondaryMuscles, candidate.PrimaryMuscle) {
+			score += primaryAsSecondary
+		}
+		score += secondaryOverlapScore * jaccard(exercise.SecondaryMuscles, candidate.SecondaryMuscles)
+		if candidate.Mechanic == exercise.Mechanic {
+			score += sameMechanicScore
+		}
+		if candidate.Force == exercise.Force {
+			score += sameForceScore
+		}
+		substitutes = append(substitutes, Substitute{Exercise: candidate, Score: score})
+	}
+
+	slices.SortStableFunc(substitutes, func(a, b Substitute) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Name, b.Name))
+	})
+	return substitutes[:min(len(substitutes), maxSubstitutes)]
+}
+
+func usable(exercise Exercise, available []Equipment) bool {
+	if available == nil {
+		return true
+	}
+	for _, equipment := range exercise.Equipment {
+		if !slices.Contains(available, equipment) {
+			return false
+		}
+	}
+	return true
+}
+
+// jaccard returns the size of the intersection divided by the size of the
+// union of both sets.
+func jaccard[T comparable](a, b []T) float64 {
+	union := map[T]bool{}
+	for _, v := range a {
+		union[v] = false
+	}
+	intersection := 0
+	for _, v := range b {
+		if seen, ok := union[v]; ok && !seen {
+			intersection++
+		}
+		union[v] = true
+	}
+	if len(union) == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(len(union))
+}
+
+// findSubstitutes ranks all exercises training one of the muscles of
+// exercise as its primary muscle.
+func (a *App) findSubstitutes(exercise Exercise, available []Equipment) ([]Substitute, error) {
+	muscles := append([]Muscle{exercise.PrimaryMuscle}, exercise.SecondaryMuscles...)
+	candidates, err := gorm.G[Exercise](a.db).
+		Where("primary_muscle = ANY(?) AND id <> ? AND trashed_at IS NULL", pq.Array(muscles), exercise.ID).
+		Find(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rankSubstitutes(exercise, candidates, available), nil
+}
+
+// SubstituteFilter holds the equipment available for substitutes. Without
+// the restricted flag the filter was not submitted yet and the defaults apply.
+type SubstituteFilter struct {
+	Equipment  []Equipment `form:"equipment" binding:"dive,enum"`
+	Restricted bool        `form:"restricted"`
+}
+
+func (f SubstituteFilter) available(defaults []Equipment) []Equipment {
+	if !f.Restricted {
+		return defaults
+	}
+	if f.Equipment == nil {
+		return []Equipment{}
+	}
+	return f.Equipment
+}
+
+// ExerciseSubstitutes lists the substitutes for the exercise page, by default
+// all equipment is available.
+func (a *App) ExerciseSubstitutes(c *gin.Context) {
+	id := c.Param("id")
+	data := map[string]any{
+		"Link": "/exercise/" + id + "/substitutes",
+	}
+	exercise, err := gorm.G[Exercise](a.db).Where("id = ?", id).First(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+		a.renderSubstitutes(c, data, err)
+		return
+	}
+
+	all := catalogValues[equipmentNames](a.catalog)
+	a.substitutes(c, exercise, all, data)
+}
+
+func (a *App) substitutes(c *gin.Context, exercise Exercise, defaults []Equipment, data map[string]any) {
+	var filter SubstituteFilter
+	err := c.ShouldBindWith(&filter, binding.Query)
+	if err == nil {
+		available := filter.available(defaults)
+		data["Available"] = available
+		data["Substitutes"], err = a.findSubstitutes(exercise, available)
+	}
+	if err != nil {
+		log.Printf("substitute error: %v", err)
+	}
+	a.renderSubstitutes(c, data, err)
+}
+
+func (a *App) renderSubstitutes(c *gin.Context, data map[string]any, err error) {
+	data["PossibleValues"] = a.possibleValues()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	substitutes := htmx.NewComponent("templates/components/exercise_substitutes.html").
+		SetData(data).
+		AddTemplateFunction("has", has).
+		AddTemplateFunction("join", join)
+	a.render(c, &substitutes)
+}
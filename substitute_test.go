@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankSubstitutes(t *testing.T) {
+	bench := Exercise{ID: 1, Name: "Bench Press", Force: Push, Mechanic: Compound,
+		PrimaryMuscle: "Chest", SecondaryMuscles: []Muscle{"Shoulders", "Triceps"},
+		Equipment: []Equipment{"Barbell", "Bench"}}
+	candidates := []Exercise{
+		bench,
+		{ID: 2, Name: "Dumbbell Press", Force: Push, Mechanic: Compound,
+			PrimaryMuscle: "Chest", SecondaryMuscles: []Muscle{"Shoulders", "Triceps"},
+			Equipment: []Equipment{"Dumbbells", "Bench"}},
+		{ID: 3, Name: "Push-Up", Force: Push, Mechanic: Compound,
+			PrimaryMuscle: "Chest", SecondaryMuscles: []Muscle{"Triceps"},
+			Equipment: []Equipment{"Body"}},
+		{ID: 4, Name: "Dip", Force: Push, Mechanic: Compound,
+			PrimaryMuscle: "Triceps", SecondaryMuscles: []Muscle{"Chest", "Shoulders"},
+			Equipment: []Equipment{"Body"}},
+		{ID: 5, Name: "Cable Fly", Force: Push, Mechanic: Isolation,
+			PrimaryMuscle: "Chest", SecondaryMuscles: []Muscle{},
+			Equipment: []Equipment{"Cable"}},
+	}
+
+	tests := []struct {
+		name      string
+		available []Equipment
+		expected  []uint
+		scores    []float64
+	}{
+		{"any equipment", nil, []uint{2, 3, 5, 4}, []float64{10, 8.5, 6, 5}},
+		{"barbell busy", []Equipment{"Bench", "Body", "Cable", "Dumbbells"}, []uint{2, 3, 5, 4}, []float64{10, 8.5, 6, 5}},
+		{"body weight only", []Equipment{"Body"}, []uint{3, 4}, []float64{8.5, 5}},
+		{"nothing available", []Equipment{}, []uint{}, []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []uint{}
+			scores := []float64{}
+			for _, substitute := range rankSubstitutes(bench, candidates, tt.available) {
+				ids = append(ids, substitute.ID)
+				scores = append(scores, substitute.Score)
+			}
+			assert.Equal(t, tt.expected, ids)
+			assert.Equal(t, tt.scores, scores)
+		})
+	}
+}
+
+func TestExerciseSubstitutes(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		url     string
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"/exercise/2/substitutes",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("2", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE primary_muscle = ANY($1) AND id <> $2 AND trashed_at IS NULL`).
+					WithArgs(`{"Hamstrings","Abductors","Chest"}`, 2).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+			},
+			"./fixtures/exercise/substitutes.html",
+		},
+		{
+			"/exercise/2/substitutes?restricted=true&equipment=Bands",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("2", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE primary_muscle = ANY($1) AND id <> $2 AND trashed_at IS NULL`).
+					WithArgs(`{"Hamstrings","Abductors","Chest"}`, 2).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+			},
+			"./fixtures/exercise/substitutes_restricted.html",
+		},
+		{
+			"/exercise/3/substitutes",
+			func() {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("3", 1).
+					WillReturnError(fmt.Errorf("test substitutes error"))
+			},
+			"./fixtures/exercise/substitutes_error.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestUnitSubstitutes(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/workout/1/unit/3/substitutes", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+		WithArgs("3", "1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "WorkoutID", "UnitID", "Position", "ExerciseID"}).
+			AddRow(3, 1, 7, 0, 2))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE primary_muscle = ANY($1) AND id <> $2 AND trashed_at IS NULL`).
+		WithArgs(`{"Hamstrings","Abductors","Chest"}`, 2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+	router.ServeHTTP(w, req)
+
+	// the equipment of the swapped exercise is busy, so ex1 needing "Other"
+	// is no substitute
+	validateFixture(t, "./fixtures/workout/substitutes.html", w)
+
+	// the body weight is never busy, a push-up can be swapped to a dip
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/workout/1/unit/4/substitutes", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+		WithArgs("4", "1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "WorkoutID", "UnitID", "Position", "ExerciseID"}).
+			AddRow(4, 1, 8, 1, 3))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(exCols).
+			AddRow(3, t1, t1, "Push-Up", "Push", "Easy", "Compound", "Strength", "Chest", `["Triceps"]`, `["Body"]`, "", "[]"))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE primary_muscle = ANY($1) AND id <> $2 AND trashed_at IS NULL`).
+		WithArgs(`{"Chest","Triceps"}`, 3).
+		WillReturnRows(sqlmock.NewRows(exCols).
+			AddRow(4, t1, t1, "Dip", "Push", "Middle", "Compound", "Strength", "Triceps", `["Chest"]`, `["Body"]`, "", "[]"))
+	router.ServeHTTP(w, req)
+	validateFixture(t, "./fixtures/workout/substitutes_body.html", w)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timerTick is the length of a second of the timer, shortened in tests.
+var timerTick = time.Second
+
+// Interval is a period of work or rest of a timed set, a completed work
+// interval logs a set of its unit, an AMRAP without one of every unit.
+type Interval struct {
+	Work    bool
+	Unit    *WorkoutUnit
+	Round   int
+	Seconds int
+}
+
+// TimerTick is a second of the timer as sent to the browser, Cue names the
+// sound to play.
+type TimerTick struct {
+	Phase     string `json:"phase"`
+	Exercise  string `json:"exercise"`
+	Round     int    `json:"round"`
+	Rounds    int    `json:"rounds"`
+	Remaining int    `json:"remaining"`
+	Cue       string `json:"cue"`
+}
+
+// TimerPreset is a common setup of a timed set.
+type TimerPreset struct {
+	Name    string
+	Options SetOptions
+}
+
+var timerPresets = []TimerPreset{
+	{"Tabata", SetOptions{Type: TimedSet, Rounds: 8, Work: 20, Rest: 10}},
+	{"EMOM 10", SetOptions{Type: EMOM, Rounds: 10, Work: 60}},
+	{"E2MOM 5", SetOptions{Type: EMOM, Rounds: 5, Work: 120}},
+	{"AMRAP 12", SetOptions{Type: AMRAP, Work: 720}},
+}
+
+// Values returns the options as hx-vals of the form saving a set.
+func (p TimerPreset) Values() string {
+	values, _ := json.Marshal(map[string]any{
+		"type":   p.Options.Type,
+		"rounds": p.Options.Rounds,
+		"work":   p.Options.Work,
+		"rest":   p.Options.Rest,
+	})
+	return string(values)
+}
+
+// Timed tells whether the set is performed on a timer.
+func (o SetOptions) Timed() bool {
+	return o.Work > 0 && (o.Type == AMRAP || o.Type == EMOM || o.Type == TimedSet)
+}
+
+// Intervals returns the intervals of a timed set. An AMRAP is a single work
+// interval, EMOM and timed sets go through the units in turn, one per round.
+func (g WorkoutGroup) Intervals() ([]Interval, error) {
+	if !g.Set.Timed() || len(g.Units) == 0 {
+		return nil, errors.New("a " + g.Set.Type.String() + " set has no timer")
+	}
+	// a set without rounds or times would have nothing to time
+	options := g.Set.SetOptions
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	if g.Set.Type == AMRAP {
+		return []Interval{{Work: true, Round: 1, Seconds: g.Set.Work}}, nil
+	}
+	intervals := []Interval{}
+	for round := range g.Set.Rounds {
+		unit := &g.Units[round%len(g.Units)]
+		intervals = append(intervals, Interval{Work: true, Unit: unit, Round: round + 1, Seconds: g.Set.Work})
+		if g.Set.Type == TimedSet && g.Set.Rest > 0 && round < g.Set.Rounds-1 {
+			intervals = append(intervals, Interval{Round: round + 1, Seconds: g.Set.Rest})
+		}
+	}
+	return intervals, nil
+}
+
+// tick returns the second of the interval, the first cues its start and the
+// last three count down.
+func (i Interval) tick(rounds, second int) TimerTick {
+	tick := TimerTick{Phase: "rest", Round: i.Round, Rounds: rounds, Remaining: i.Seconds - second}
+	if i.Work {
+		tick.Phase = "work"
+	}
+	if i.Unit != nil {
+		tick.Exercise = i.Unit.Exercise.Name
+	}
+	if second == 0 {
+		tick.Cue = tick.Phase
+	} else if tick.Remaining <= 3 {
+		tick.Cue = "countdown"
+	}
+	return tick
+}
+
+// loggedUnits returns the units a completed work interval logs a set of, an
+// AMRAP logs a round of all units.
+func (g WorkoutGroup) loggedUnits(interval Interval) []*WorkoutUnit {
+	if interval.Unit != nil {
+		return []*WorkoutUnit{interval.Unit}
+	}
+	units := []*WorkoutUnit{}
+	for i := range g.Units {
+		units = append(units, &g.Units[i])
+	}
+	return units
+}
+
+// WorkoutTimer streams the timer of a timed set of the workout as server
+// sent events, the sets of the completed work intervals are logged with the
+// targets of the unit. The stream is a GET of an EventSource, a reconnect
+// restarts the timer but logs every unit and round once only.
+func (a *App) WorkoutTimer(c *gin.Context) {
+	workout, err := a.loadWorkout(c.Param("id"))
+	var group WorkoutGroup
+	if err == nil && workout.FinishedAt != nil {
+		err = errors.New("the workout is finished")
+	}
+	if err == nil {
+		err = errors.New("the set is not part of the workout")
+		for _, g := range workout.Groups() {
+			if strconv.FormatUint(uint64(g.Set.ID), 10) == c.Param("set") {
+				group, err = g, nil
+			}
+		}
+	}
+	var intervals []Interval
+	if err == nil {
+		intervals, err = group.Intervals()
+	}
+	if err != nil {
+		log.Printf("timer error: %v", err)
+		c.SSEvent("failure", err.Error())
+		return
+	}
+
+	done := c.Request.Context().Done()
+	rounds := intervals[len(intervals)-1].Round
+	for _, interval := range intervals {
+		for second := range interval.Seconds {
+			c.SSEvent("tick", interval.tick(rounds, second))
+			c.Writer.Flush()
+			select {
+			case <-done:
+				return
+			case <-time.After(timerTick):
+			}
+		}
+		if !interval.Work {
+			continue
+		}
+		for _, unit := range group.loggedUnits(interval) {
+			if slices.ContainsFunc(unit.Sets, func(set LoggedSet) bool { return set.TimerRound == interval.Round }) {
+				continue
+			}
+			set := LoggedSet{WorkoutUnitID: unit.ID, ExerciseID: unit.ExerciseID, TimerRound: interval.Round}
+			if targets := workout.Targets(*unit); targets != nil {
+				set.Reps, set.Weight = targets.Reps, targets.Weight
+			}
+			err = a.logSet(c, &set)
+			if err != nil {
+				log.Printf("timer error: %v", err)
+				c.SSEvent("failure", err.Error())
+				return
+			}
+			c.SSEvent("logged", unit.Exercise.Name)
+		}
+	}
+	c.SSEvent("done", "")
+	c.Writer.Flush()
+}
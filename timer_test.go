@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntervals(t *testing.T) {
+	units := []WorkoutUnit{{ID: 1}, {ID: 2}}
+	group := WorkoutGroup{Set: Set{SetOptions: SetOptions{Type: TimedSet, Rounds: 3, Work: 20, Rest: 10}}, Units: units}
+	intervals, err := group.Intervals()
+	assert.NoError(t, err)
+	assert.Equal(t, []Interval{
+		{Work: true, Unit: &group.Units[0], Round: 1, Seconds: 20},
+		{Round: 1, Seconds: 10},
+		{Work: true, Unit: &group.Units[1], Round: 2, Seconds: 20},
+		{Round: 2, Seconds: 10},
+		{Work: true, Unit: &group.Units[0], Round: 3, Seconds: 20},
+	}, intervals)
+
+	group.Set.SetOptions = SetOptions{Type: EMOM, Rounds: 2, Work: 60, Rest: 10}
+	intervals, err = group.Intervals()
+	assert.NoError(t, err)
+	assert.Equal(t, []Interval{
+		{Work: true, Unit: &group.Units[0], Round: 1, Seconds: 60},
+		{Work: true, Unit: &group.Units[1], Round: 2, Seconds: 60},
+	}, intervals)
+
+	group.Set.SetOptions = SetOptions{Type: AMRAP, Work: 600}
+	intervals, err = group.Intervals()
+	assert.NoError(t, err)
+	assert.Equal(t, []Interval{{Work: true, Round: 1, Seconds: 600}}, intervals)
+
+	group.Set.SetOptions = SetOptions{Type: EMOM, Rounds: -2, Work: 60}
+	_, err = group.Intervals()
+	assert.EqualError(t, err, "an EMOM needs the number of rounds and the interval")
+
+	group.Set.SetOptions = SetOptions{Type: AMRAP, Work: 1 << 40}
+	_, err = group.Intervals()
+	assert.EqualError(t, err, "a set has at most 60:00 min of work and rest")
+
+	group.Set.SetOptions = SetOptions{Type: Circuit, Rounds: 3}
+	_, err = group.Intervals()
+	assert.EqualError(t, err, "a Circuit set has no timer")
+}
+
+func TestIntervalTick(t *testing.T) {
+	ticks := func(interval Interval) []TimerTick {
+		ticks := []TimerTick{}
+		for second := range interval.Seconds {
+			ticks = append(ticks, interval.tick(8, second))
+		}
+		return ticks
+	}
+	unit := WorkoutUnit{Exercise: Exercise{Name: "burpee"}}
+	assert.Equal(t, []TimerTick{
+		{Phase: "work", Exercise: "burpee", Round: 1, Rounds: 8, Remaining: 5, Cue: "work"},
+		{Phase: "work", Exercise: "burpee", Round: 1, Rounds: 8, Remaining: 4},
+		{Phase: "work", Exercise: "burpee", Round: 1, Rounds: 8, Remaining: 3, Cue: "countdown"},
+		{Phase: "work", Exercise: "burpee", Round: 1, Rounds: 8, Remaining: 2, Cue: "countdown"},
+		{Phase: "work", Exercise: "burpee", Round: 1, Rounds: 8, Remaining: 1, Cue: "countdown"},
+	}, ticks(Interval{Work: true, Unit: &unit, Round: 1, Seconds: 5}))
+	assert.Equal(t, []TimerTick{
+		{Phase: "rest", Round: 2, Rounds: 8, Remaining: 2, Cue: "rest"},
+		{Phase: "rest", Round: 2, Rounds: 8, Remaining: 1, Cue: "countdown"},
+	}, ticks(Interval{Round: 2, Seconds: 2}))
+}
+
+func TestTimerPresetValues(t *testing.T) {
+	assert.Equal(t, `{"rest":10,"rounds":8,"type":"Timed","work":20}`, timerPresets[0].Values())
+}
+
+func TestWorkoutTimer(t *testing.T) {
+	router, _ := SetupTestApp()
+	timerTick = 0
+	defer func() { timerTick = time.Second }()
+
+	expectTimerWorkout := func(logged *sqlmock.Rows, loggedSet int, set ...driver.Value) {
+		mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+			WithArgs("5", 1).
+			WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, 1, nil, 100.0))
+		mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+		mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(setCols).AddRow(set...))
+		mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(1, 5, 7, 0, 2).AddRow(2, 5, 8, 1, 1))
+		mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" IN ($1,$2)`).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...).AddRow(ex1...))
+		mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" IN ($1,$2) ORDER BY id`).
+			WithArgs(1, 2).
+			WillReturnRows(logged)
+		if loggedSet != 0 {
+			mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE "personal_records"."logged_set_id" = $1 ORDER BY id`).
+				WithArgs(loggedSet).
+				WillReturnRows(sqlmock.NewRows([]string{"ID"}))
+		}
+		mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."id" IN ($1,$2)`).
+			WithArgs(7, 8).
+			WillReturnRows(sqlmock.NewRows(unitCols).
+				AddRow(7, 4, 0, 2, 0, 0, 10, 20.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0).
+				AddRow(8, 4, 1, 1, 0, 0, 15, 0.0, "None", 0.0, 0, 0, 0.0, 0.0, 0.0))
+	}
+	// timer sets hold the targets, they are stored without records
+	expectLog := func(unit, exercise, reps int, weight float64, round int) {
+		mocksql.ExpectBegin()
+		mocksql.ExpectQuery(`INSERT INTO "logged_sets" ("created_at","workout_unit_id","exercise_id","reps","weight","rpe","drop","estimated_max","timer_round") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`).
+			WithArgs(sqlmock.AnyArg(), unit, exercise, reps, weight, 0.0, false, 0.0, round).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mocksql.ExpectCommit()
+	}
+	noSets := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"ID"}) }
+
+	tests := []struct {
+		fixture string
+		expect  func()
+	}{
+		{"emom.txt", func() {
+			expectTimerWorkout(noSets(), 0, 4, 1, 0, "EMOM", 2, 0, 4)
+			expectLog(1, 2, 10, 20.0, 1)
+			expectLog(2, 1, 15, 0.0, 2)
+		}},
+		{"emom_reconnect.txt", func() {
+			// the first round was logged before the browser reconnected
+			expectTimerWorkout(sqlmock.NewRows([]string{"ID", "WorkoutUnitID", "Reps", "Weight", "TimerRound"}).
+				AddRow(9, 1, 10, 20.0, 1), 9, 4, 1, 0, "EMOM", 2, 0, 4)
+			expectLog(2, 1, 15, 0.0, 2)
+		}},
+		{"amrap.txt", func() {
+			expectTimerWorkout(noSets(), 0, 4, 1, 0, "AMRAP", 0, 0, 3)
+			expectLog(1, 2, 10, 20.0, 1)
+			expectLog(2, 1, 15, 0.0, 1)
+		}},
+		{"not_timed.txt", func() {
+			expectTimerWorkout(noSets(), 0, 4, 1, 0, "Superset", 0, 0, 0)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/workout/5/set/4/timer", nil)
+			test.expect()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, "text/event-stream;charset=utf-8", w.Header().Get("Content-Type"))
+			validateFixture(t, "./fixtures/timer/"+test.fixture, w)
+			if err := mocksql.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
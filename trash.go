@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// trashRetention is how long exercises stay in the trash before they are
+// purged.
+const trashRetention = 30 * 24 * time.Hour
+
+// referencedCondition matches exercises used by a plan or a workout, those
+// are kept in the trash and never deleted permanently.
+const referencedCondition = `(
+	EXISTS (SELECT 1 FROM units WHERE units.exercise_id = exercises.id)
+	OR EXISTS (SELECT 1 FROM workout_units WHERE workout_units.exercise_id = exercises.id)
+	OR EXISTS (SELECT 1 FROM logged_sets WHERE logged_sets.exercise_id = exercises.id)
+)`
+
+// TrashedExercise is an exercise in the trash, Referenced exercises are only
+// archived.
+type TrashedExercise struct {
+	Exercise
+	Referenced bool
+}
+
+// DeleteExercise moves the exercise to the trash, it can be restored until it
+// is purged.
+func (a *App) DeleteExercise(c *gin.Context) {
+	err := a.setTrashed(c, c.Param("id"), true)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.ListExercises(c)
+}
+
+func (a *App) ListTrash(c *gin.Context) {
+	a.renderTrash(c, nil)
+}
+
+func (a *App) RestoreExercise(c *gin.Context) {
+	err := a.setTrashed(c, c.Param("id"), false)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.renderTrash(c, err)
+}
+
+// PurgeExercise deletes an exercise in the trash permanently.
+func (a *App) PurgeExercise(c *gin.Context) {
+	exercise, err := gorm.G[Exercise](a.db).
+		Where("id = ? AND trashed_at IS NOT NULL", c.Param("id")).
+		First(*a.ctx)
+	if err == nil {
+		err = a.purgeExercise(exercise)
+	}
+	if err != nil {
+		log.Printf("purge error: %v", err)
+	}
+	a.renderTrash(c, err)
+}
+
+func (a *App) renderTrash(c *gin.Context, err error) {
+	exercises, listErr := gorm.G[TrashedExercise](a.db).
+		Table("exercises").
+		Select("*, " + referencedCondition + " AS referenced").
+		Where("trashed_at IS NOT NULL").
+		Order("trashed_at DESC").
+		Find(*a.ctx)
+	if listErr != nil {
+		log.Printf("db error: %v", listErr)
+		err = errors.Join(err, listErr)
+	}
+
+	data := map[string]any{
+		"Exercises": exercises,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/trash.html").
+		SetData(data).
+		AddTemplateFunction("purgeDate", func(e TrashedExercise) time.Time {
+			return e.TrashedAt.Add(trashRetention)
+		}).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// setTrashed moves the exercise into or out of the trash and records the
+// change in its history.
+func (a *App) setTrashed(c *gin.Context, id string, trashed bool) error {
+	condition, action := "trashed_at IS NOT NULL", Restored
+	var trashedAt *time.Time
+	if trashed {
+		now := a.now()
+		condition, action, trashedAt = "trashed_at IS NULL", Trashed, &now
+	}
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		exercise, err := gorm.G[Exercise](tx).
+			Preload("Parent", nil).
+			Where("id = ? AND "+condition, id).
+			First(*a.ctx)
+		if err != nil {
+			return err
+		}
+		_, err = gorm.G[Exercise](tx).Where("id = ?", exercise.ID).Update(*a.ctx, "trashed_at", trashedAt)
+		if err != nil {
+			return err
+		}
+		exercise.TrashedAt = trashedAt
+		return a.recordRevision(tx, currentUser(c), action, exercise)
+	})
+}
+
+// purgeExercise deletes the exercise with its history and the images of all
+// revisions, variations move up to the parent of the exercise.
+func (a *App) purgeExercise(exercise Exercise) error {
+	images := exercise.Images
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		count, err := gorm.G[Exercise](tx).
+			Where("id = ? AND "+referencedCondition, exercise.ID).
+			Count(*a.ctx, "id")
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("exercise '" + exercise.Name + "' is used by plans or workouts and can only be archived")
+		}
+
+		_, err = gorm.G[Exercise](tx).
+			Where("parent_id = ?", exercise.ID).
+			Update(*a.ctx, "parent_id", exercise.ParentID)
+		if err != nil {
+			return err
+		}
+		revisionImages, err := a.revisionImages(tx, exercise.ID)
+		if err != nil {
+			return err
+		}
+		for _, image := range revisionImages {
+			if !slices.Contains(images, image) {
+				images = append(images, image)
+			}
+		}
+		_, err = gorm.G[ExerciseRevision](tx).Where("exercise_id = ?", exercise.ID).Delete(*a.ctx)
+		if err != nil {
+			return err
+		}
+		_, err = gorm.G[Exercise](tx).Where("id = ?", exercise.ID).Delete(*a.ctx)
+		if err != nil {
+			return err
+		}
+		images, err = a.unusedImages(tx, images)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// images are removed last, a failed delete keeps the exercise restorable
+	a.deleteImages(images)
+	return nil
+}
+
+// unusedImages returns the images no exercise or revision references
+// anymore, images uploaded before the unique names were named after the
+// exercise and can be shared.
+func (a *App) unusedImages(tx *gorm.DB, images []string) ([]string, error) {
+	if len(images) == 0 {
+		return images, nil
+	}
+	exercises, err := gorm.G[Exercise](tx).
+		Select("images").
+		Where("jsonb_exists_any(images, ?)", pq.Array(images)).
+		Find(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := gorm.G[ExerciseRevision](tx).
+		Select("snapshot").
+		Where("jsonb_exists_any(snapshot->'Images', ?)", pq.Array(images)).
+		Find(*a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range revisions {
+		exercises = append(exercises, revision.Snapshot)
+	}
+	return slices.DeleteFunc(images, func(image string) bool {
+		return slices.ContainsFunc(exercises, func(exercise Exercise) bool {
+			return slices.Contains(exercise.Images, image)
+		})
+	}), nil
+}
+
+// purgeTrash permanently deletes the unreferenced exercises trashed before
+// the given time.
+func (a *App) purgeTrash(before time.Time) (int, error) {
+	exercises, err := gorm.G[Exercise](a.db).
+		Where("trashed_at < ? AND NOT "+referencedCondition, before).
+		Find(*a.ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, exercise := range exercises {
+		if err := a.purgeExercise(exercise); err != nil {
+			log.Printf("purge error: %v", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeTrashEvery purges the trash periodically until the app context is
+// done.
+func (a *App) purgeTrashEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := a.purgeTrash(a.now().Add(-trashRetention))
+		if err != nil {
+			log.Printf("db error: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d exercises from the trash", purged)
+		}
+
+		select {
+		case <-(*a.ctx).Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	trashCols = append(append([]string{}, exCols...), "ParentID", "TrashedAt", "Referenced")
+	trashedAt = time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	trashed1  = append(append([]driver.Value{}, ex1...), nil, trashedAt, false)
+	trashed2  = append(append([]driver.Value{}, ex2...), nil, trashedAt, true)
+	listTrash = `SELECT *, ` + referencedCondition + ` AS referenced FROM "exercises" WHERE trashed_at IS NOT NULL ORDER BY trashed_at DESC`
+)
+
+func TestTrash(t *testing.T) {
+	router, app := SetupTestApp()
+
+	tests := []struct {
+		method  string
+		url     string
+		dbmocks func(*App)
+		fixture string
+	}{
+		{
+			"GET", "/exercise/trash",
+			func(a *App) {
+				mocksql.ExpectQuery(listTrash).
+					WillReturnRows(sqlmock.NewRows(trashCols).AddRow(trashed1...).AddRow(trashed2...))
+			},
+			"./fixtures/trash/list.html",
+		},
+		{
+			"POST", "/exercise/1/restore",
+			func(a *App) {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 AND trashed_at IS NOT NULL ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "trashed_at"=$1,"updated_at"=$2 WHERE id = $3`).
+					WithArgs(nil, sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectRevision(1, Restored)
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(listTrash).
+					WillReturnRows(sqlmock.NewRows(trashCols).AddRow(trashed2...))
+			},
+			"./fixtures/trash/restore.html",
+		},
+		{
+			"DELETE", "/exercise/1/purge",
+			func(a *App) {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 AND trashed_at IS NOT NULL ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("1", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE id = $1 AND ` + referencedCondition).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mocksql.ExpectExec(`UPDATE "exercises" SET "parent_id"=$1,"updated_at"=$2 WHERE parent_id = $3`).
+					WithArgs(nil, sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(revisionCols).AddRow(revision1...).AddRow(revision2...))
+				mocksql.ExpectExec(`DELETE FROM "exercise_revisions" WHERE exercise_id = $1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mocksql.ExpectExec(`DELETE FROM "exercises" WHERE id = $1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// an exercise once named like this one still uses an image
+				mocksql.ExpectQuery(`SELECT "images" FROM "exercises" WHERE jsonb_exists_any(images, $1)`).
+					WithArgs(`{"fff_0","fff_1","fff_old_0"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"images"}).AddRow(`["fff_1"]`))
+				mocksql.ExpectQuery(`SELECT "snapshot" FROM "exercise_revisions" WHERE jsonb_exists_any(snapshot->'Images', $1)`).
+					WithArgs(`{"fff_0","fff_1","fff_old_0"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"snapshot"}))
+				mocksql.ExpectCommit()
+				mocksql.ExpectQuery(listTrash).
+					WillReturnRows(sqlmock.NewRows(trashCols).AddRow(trashed2...))
+				mockRM := &mockRM{}
+				mockRM.On("Remove", "./static/images/fff_0").Return(nil)
+				mockRM.On("Remove", "./static/images/fff_old_0").Return(nil)
+				a.mockRM = mockRM
+			},
+			"./fixtures/trash/purge.html",
+		},
+		{
+			"DELETE", "/exercise/2/purge",
+			func(a *App) {
+				mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE id = $1 AND trashed_at IS NOT NULL ORDER BY "exercises"."id" LIMIT $2`).
+					WithArgs("2", 1).
+					WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE id = $1 AND ` + referencedCondition).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mocksql.ExpectRollback()
+				mocksql.ExpectQuery(listTrash).
+					WillReturnError(fmt.Errorf("test trash error"))
+				a.mockRM = &mockRM{}
+			},
+			"./fixtures/trash/purge_referenced.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks(app)
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+			app.mockRM.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	_, app := SetupTestApp()
+	before := time.Now()
+
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at < $1 AND NOT ` + referencedCondition).
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "exercises" WHERE id = $1 AND ` + referencedCondition).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mocksql.ExpectExec(`UPDATE "exercises" SET "parent_id"=$1,"updated_at"=$2 WHERE parent_id = $3`).
+		WithArgs(nil, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mocksql.ExpectQuery(`SELECT * FROM "exercise_revisions" WHERE exercise_id = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(revisionCols))
+	mocksql.ExpectExec(`DELETE FROM "exercise_revisions" WHERE exercise_id = $1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mocksql.ExpectExec(`DELETE FROM "exercises" WHERE id = $1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+
+	purged, err := app.purgeTrash(before)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+}
+
+func TestPurgeTrashEvery(t *testing.T) {
+	_, app := SetupTestApp()
+	app.mockNow = &trashedAt
+	ctx, cancel := context.WithCancel(context.Background())
+	app.ctx = &ctx
+
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE trashed_at < $1 AND NOT ` + referencedCondition).
+		WithArgs(trashedAt.Add(-trashRetention)).
+		WillReturnRows(sqlmock.NewRows(exCols))
+	done := make(chan struct{})
+	go func() {
+		app.purgeTrashEvery(time.Hour)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return mocksql.ExpectationsWereMet() == nil }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
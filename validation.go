@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// enum is implemented by the enumerations bound from forms, validIn reports
+// whether the value is one of the defined ones.
+type enum interface {
+	validIn(c *Catalog) bool
+}
+
+// FieldErrors maps form fields to the message shown next to them.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = e[field]
+	}
+	return strings.Join(messages, "\n")
+}
+
+// registerValidations adds the custom validations used in binding tags, the
+// enumerations kept in the catalog are validated against it.
+func registerValidations(catalog *Catalog) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		value, ok := fl.Field().Interface().(enum)
+		return ok && value.validIn(catalog)
+	})
+}
+
+// fieldErrors translates the errors of binding form into messages per form
+// field, other errors are returned unchanged.
+func fieldErrors(err error, form any) error {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := FieldErrors{}
+	formType := reflect.TypeOf(form)
+	for _, fe := range invalid {
+		// elements of lists are reported as Field[idx]
+		name, _, _ := strings.Cut(fe.StructField(), "[")
+		field, ok := formType.FieldByName(name)
+		if !ok {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if _, ok := fields[key]; !ok {
+			fields[key] = validationMessage(fe)
+		}
+	}
+	return fields
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "this field is required"
+	case "enum":
+		if fmt.Sprint(fe.Value()) == "" {
+			return "this field is required"
+		}
+		return fmt.Sprintf("'%v' is not a valid choice", fe.Value())
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	default:
+		return "invalid value"
+	}
+}
+
+// fieldError returns the message for the form field, errors is nil if the
+// form has no field errors.
+func fieldError(errors any, field string) string {
+	fields, _ := errors.(FieldErrors)
+	return fields[field]
+}
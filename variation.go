@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Variation is an exercise in the variation tree of a movement, Depth is the
+// distance to the movement at the root of the tree.
+type Variation struct {
+	Exercise
+	Depth int
+}
+
+// maxDepth guards the recursive queries against cycles in the hierarchy.
+const maxDepth = 16
+
+// ancestorsQuery walks up the variation hierarchy from the exercise, the root
+// movement comes last.
+const ancestorsQuery = `
+	WITH RECURSIVE up AS (
+		SELECT id, parent_id, 0 AS depth FROM exercises WHERE id = ?
+		UNION ALL
+		SELECT e.id, e.parent_id, up.depth + 1 FROM exercises e JOIN up ON e.id = up.parent_id
+		WHERE up.depth < ?
+	)
+	SELECT id FROM up ORDER BY depth`
+
+// familyQuery selects the movement with all of its variations, every
+// variation follows its parent.
+const familyQuery = `
+	WITH RECURSIVE family AS (
+		SELECT exercises.*, 0 AS depth, ARRAY[name]::text[] AS path FROM exercises WHERE id = ?
+		UNION ALL
+		SELECT e.*, family.depth + 1, family.path || e.name::text FROM exercises e JOIN family ON e.parent_id = family.id
+		WHERE family.depth < ?
+	)
+	SELECT * FROM family ORDER BY path`
+
+// nameCondition matches exercises using one of the lower case names as name
+// or alias.
+const nameCondition = `LOWER(name) = ANY(?) OR EXISTS (
+	SELECT 1 FROM jsonb_array_elements_text(aliases) alias
+	WHERE LOWER(alias) = ANY(?)
+)`
+
+func (a *App) ancestors(id uint) ([]uint, error) {
+	var ids []uint
+	err := a.db.WithContext(*a.ctx).Raw(ancestorsQuery, id, maxDepth).Scan(&ids).Error
+	return ids, err
+}
+
+// movement returns the id of the root movement the exercise is a variation
+// of.
+func (a *App) movement(id uint) (uint, error) {
+	ids, err := a.ancestors(id)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return id, nil
+	}
+	return ids[len(ids)-1], nil
+}
+
+// family returns the variation tree of the movement of the exercise, the
+// progress rolls it up.
+func (a *App) family(id uint) ([]Variation, error) {
+	root, err := a.movement(id)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.G[Variation](a.db).Raw(familyQuery, root, maxDepth).Find(*a.ctx)
+}
+
+func (a *App) ExerciseVariations(c *gin.Context) {
+	var variations []Variation
+	id, err := a.exerciseID(c.Param("id"))
+	if err == nil {
+		variations, err = a.family(id)
+	}
+	data := map[string]any{
+		"ID":         id,
+		"Variations": variations,
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		data["Error"] = err.Error()
+	}
+	component := htmx.NewComponent("templates/components/exercise_variations.html").
+		SetData(data).
+		AddTemplateFunction("indent", func(depth int) string {
+			return strings.Repeat("— ", depth)
+		})
+	a.render(c, &component)
+}
+
+// ExerciseNames lists the names of all exercises as options for the parent
+// input.
+func (a *App) ExerciseNames(c *gin.Context) {
+	var names []string
+	err := a.db.WithContext(*a.ctx).Model(&Exercise{}).Where("trashed_at IS NULL").Order("name").Pluck("name", &names).Error
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	component := htmx.NewComponent("templates/components/exercise_names.html").
+		SetData(map[string]any{"Names": names})
+	a.render(c, &component)
+}
+
+func (a *App) exerciseID(id string) (uint, error) {
+	exercise, err := gorm.G[Exercise](a.db).Select("id").Where("id = ?", id).First(*a.ctx)
+	return exercise.ID, err
+}
+
+// nameTaken checks the name and aliases of the exercise against the names and
+// aliases of all other exercises, id is the exercise when it is updated.
+func (a *App) nameTaken(exercise *Exercise, id uint) error {
+	names := []string{strings.ToLower(exercise.Name)}
+	for _, alias := range exercise.Aliases {
+		names = append(names, strings.ToLower(alias))
+	}
+
+	query := gorm.G[Exercise](a.db).Where(nameCondition, pq.Array(names), pq.Array(names))
+	if id != 0 {
+		query = query.Where("id <> ?", id)
+	}
+	other, err := query.Take(*a.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range other.Aliases {
+		if slices.Contains(names, strings.ToLower(alias)) {
+			return FieldErrors{"aliases": "'" + alias + "' is already an alias of exercise '" + other.Name + "'"}
+		}
+	}
+	if other.TrashedAt != nil {
+		return FieldErrors{"name": "exercise with name '" + other.Name + "' already exists in the trash"}
+	}
+	return FieldErrors{"name": "exercise with name '" + other.Name + "' already exists"}
+}
+
+// setParent resolves the parent name entered in the form. The parent must not
+// be the updated exercise with the given id or one of its variations.
+func (a *App) setParent(exercise *Exercise, id uint) error {
+	exercise.ParentID = nil
+	if exercise.ParentName == "" {
+		return nil
+	}
+
+	name := strings.ToLower(exercise.ParentName)
+	parent, err := gorm.G[Exercise](a.db).Where(nameCondition, pq.Array([]string{name}), pq.Array([]string{name})).Take(*a.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FieldErrors{"parent": "parent exercise '" + exercise.ParentName + "' does not exist"}
+	}
+	if err != nil {
+		return err
+	}
+
+	if id != 0 {
+		ids, err := a.ancestors(parent.ID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(ids, id) {
+			return FieldErrors{"parent": "exercise '" + parent.Name + "' is a variation of '" + exercise.Name + "'"}
+		}
+	}
+	exercise.ParentID = &parent.ID
+	return nil
+}
+
+// splitAliases splits the aliases entered one per line and removes empty and
+// duplicate ones.
+func splitAliases(input []string) []string {
+	aliases := []string{}
+	for _, line := range input {
+		for alias := range strings.SplitSeq(line, "\n") {
+			alias = strings.TrimSpace(alias)
+			if alias != "" && !slices.Contains(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	aliasCols = append(append([]string{}, exCols...), "Aliases", "ParentID")
+	bench     = []driver.Value{3, t1, t1, "Bench Press", "Push", "Middle", "Compound", "Strength", "Chest",
+		`["Shoulders", "Triceps"]`, `["Barbell", "Bench"]`, "press", "[]", `["Barbell Bench Press", "Flat Bench"]`, nil}
+	nameQuery = `SELECT * FROM "exercises" WHERE LOWER(name) = ANY($1) OR EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(aliases) alias WHERE LOWER(alias) = ANY($2)
+		) LIMIT $3`
+)
+
+func TestSplitAliases(t *testing.T) {
+	aliases := splitAliases([]string{"Flat Bench\r\n\n Barbell Bench Press \nFlat Bench", "BB Bench"})
+	assert.Equal(t, []string{"Flat Bench", "Barbell Bench Press", "BB Bench"}, aliases)
+	assert.Equal(t, []string{}, splitAliases(nil))
+}
+
+func TestValidateExerciseAliases(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	tests := []struct {
+		form    map[string][]string
+		dbmocks func()
+		fixture string
+	}{
+		{
+			map[string][]string{
+				"name":         {"Dumbbell Press"},
+				"aliases":      {"DB Bench\nFlat bench"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Shoulders"},
+				"equipment":    {"Dumbbells"},
+				"instructions": {"press"},
+			},
+			func() {
+				mocksql.ExpectQuery(nameQuery).
+					WithArgs(`{"dumbbell press","db bench","flat bench"}`, `{"dumbbell press","db bench","flat bench"}`, 1).
+					WillReturnRows(sqlmock.NewRows(aliasCols).AddRow(bench...))
+			},
+			"./fixtures/exercise/validate_alias_taken.html",
+		},
+		{
+			map[string][]string{
+				"name":         {"Incline Bench"},
+				"parent":       {"flat bench"},
+				"primary":      {"Abdominals"},
+				"secondary":    {"Shoulders"},
+				"equipment":    {"Barbell", "Bench"},
+				"instructions": {"press"},
+			},
+			func() {
+				mocksql.ExpectQuery(nameQuery).
+					WithArgs(`{"incline bench"}`, `{"incline bench"}`, 1).
+					WillReturnRows(sqlmock.NewRows(aliasCols))
+				mocksql.ExpectQuery(nameQuery).
+					WithArgs(`{"flat bench"}`, `{"flat bench"}`, 1).
+					WillReturnRows(sqlmock.NewRows(aliasCols))
+			},
+			"./fixtures/exercise/validate_parent_missing.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			body, writer := createForm(tt.form)
+			req, _ := http.NewRequest("POST", "/exercise/validate", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestValidateExerciseParent(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	body, writer := createForm(map[string][]string{
+		"name":         {"Incline Bench"},
+		"parent":       {"Flat Bench"},
+		"primary":      {"Abdominals"},
+		"secondary":    {"Shoulders"},
+		"equipment":    {"Barbell", "Bench"},
+		"instructions": {"press"},
+	})
+	req, _ := http.NewRequest("POST", "/exercise/validate", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	mocksql.ExpectQuery(nameQuery).
+		WithArgs(`{"incline bench"}`, `{"incline bench"}`, 1).
+		WillReturnRows(sqlmock.NewRows(aliasCols))
+	mocksql.ExpectQuery(nameQuery).
+		WithArgs(`{"flat bench"}`, `{"flat bench"}`, 1).
+		WillReturnRows(sqlmock.NewRows(aliasCols).AddRow(bench...))
+	mocksql.ExpectBegin()
+	mocksql.ExpectQuery(`INSERT INTO "exercises" ("created_at","updated_at","version","name","aliases","parent_id","force","level","mechanic","category","primary_muscle","secondary_muscles","equipment","instructions","images","trashed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "Incline Bench", "[]", 3, "Pull", "Easy", "Compound", "Endurance", "Abdominals", `["Shoulders"]`, `["Barbell","Bench"]`, "press", "[]", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	expectRevision(4, Created)
+	mocksql.ExpectCommit()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"path":"/exercise/list", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExerciseVariations(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/exercise/4/variations", nil)
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT "id" FROM "exercises" WHERE id = $1 ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs("4", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mocksql.ExpectQuery(`WITH RECURSIVE up AS (
+			SELECT id, parent_id, 0 AS depth FROM exercises WHERE id = $1
+			UNION ALL
+			SELECT e.id, e.parent_id, up.depth + 1 FROM exercises e JOIN up ON e.id = up.parent_id
+			WHERE up.depth < $2
+		)
+		SELECT id FROM up ORDER BY depth`).
+		WithArgs(4, maxDepth).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(3))
+	mocksql.ExpectQuery(`WITH RECURSIVE family AS (
+			SELECT exercises.*, 0 AS depth, ARRAY[name]::text[] AS path FROM exercises WHERE id = $1
+			UNION ALL
+			SELECT e.*, family.depth + 1, family.path || e.name::text FROM exercises e JOIN family ON e.parent_id = family.id
+			WHERE family.depth < $2
+		)
+		SELECT * FROM family ORDER BY path`).
+		WithArgs(3, maxDepth).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "Name", "ParentID", "Depth"}).
+			AddRow(3, "Bench Press", nil, 0).
+			AddRow(5, "Close Grip Bench", 3, 1).
+			AddRow(4, "Incline Bench", 3, 1).
+			AddRow(6, "Incline Dumbbell Bench", 4, 2))
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/exercise/variations.html", w)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultSecondaryCredit is the share of a set counted for each secondary
+// muscle until the user sets their own.
+const defaultSecondaryCredit = 0.5
+
+// VolumeSettings are the users settings for counting the weekly volume.
+type VolumeSettings struct {
+	Username        string `gorm:"primaryKey"`
+	UpdatedAt       time.Time
+	SecondaryCredit float64 `form:"credit" binding:"gte=0,lte=1"`
+}
+
+// VolumeTarget is the range of hard sets per week the user aims for with a
+// muscle, a maximum of 0 leaves the range open.
+type VolumeTarget struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Username  string  `gorm:"uniqueIndex:idx_volume_targets_username_muscle"`
+	Muscle    Muscle  `form:"muscle" binding:"enum" gorm:"uniqueIndex:idx_volume_targets_username_muscle"`
+	Min       float64 `form:"min" binding:"gte=0"`
+	Max       float64 `form:"max" binding:"gte=0"`
+}
+
+// MuscleVolume is the hard sets and tonnage of a muscle in a week, the sets
+// and tonnage of secondary muscles count with the credit.
+type MuscleVolume struct {
+	Muscle  Muscle
+	Child   bool
+	Sets    float64
+	Tonnage float64
+	Target  *VolumeTarget
+}
+
+// Status tells whether the sets are below or above the target range, it is
+// empty within the range or without a target.
+func (v MuscleVolume) Status() string {
+	switch {
+	case v.Target == nil:
+		return ""
+	case v.Sets < v.Target.Min:
+		return "under"
+	case v.Target.Max > 0 && v.Sets > v.Target.Max:
+		return "over"
+	}
+	return ""
+}
+
+// VolumeReport lists the weekly volume per muscle of the week given as query
+// parameter, by default the current one.
+func (a *App) VolumeReport(c *gin.Context) {
+	week, err := a.parseWeek(c.Query("week"))
+	a.renderVolume(c, week, err)
+}
+
+// SaveVolumeCredit stores the credit for secondary muscles of the user.
+func (a *App) SaveVolumeCredit(c *gin.Context) {
+	week, err := a.parseWeek(c.PostForm("week"))
+	var settings VolumeSettings
+	if err == nil {
+		err = c.ShouldBindWith(&settings, binding.Form)
+	}
+	if err == nil {
+		settings.Username = currentUser(c)
+		err = gorm.G[VolumeSettings](a.db, clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "secondary_credit"}),
+		}).Create(*a.ctx, &settings)
+	}
+	if err != nil {
+		log.Printf("volume credit error: %v", err)
+	}
+	a.renderVolume(c, week, err)
+}
+
+// SaveVolumeTarget stores the target range of a muscle, a range of 0 to 0
+// removes it.
+func (a *App) SaveVolumeTarget(c *gin.Context) {
+	week, err := a.parseWeek(c.PostForm("week"))
+	var target VolumeTarget
+	if err == nil {
+		err = c.ShouldBindWith(&target, binding.Form)
+	}
+	if err == nil && target.Max > 0 && target.Max < target.Min {
+		err = errors.New("the maximum of " + string(target.Muscle) + " is below the minimum")
+	}
+	if err == nil {
+		target.Username = currentUser(c)
+		if target.Min == 0 && target.Max == 0 {
+			_, err = gorm.G[VolumeTarget](a.db).
+				Where("username = ? AND muscle = ?", target.Username, target.Muscle).
+				Delete(*a.ctx)
+		} else {
+			err = gorm.G[VolumeTarget](a.db, clause.OnConflict{
+				Columns:   []clause.Column{{Name: "username"}, {Name: "muscle"}},
+				DoUpdates: clause.AssignmentColumns([]string{"updated_at", "min", "max"}),
+			}).Create(*a.ctx, &target)
+		}
+	}
+	if err != nil {
+		log.Printf("volume target error: %v", err)
+	}
+	a.renderVolume(c, week, err)
+}
+
+// secondaryCredit returns the credit for secondary muscles of the user.
+func (a *App) secondaryCredit(c *gin.Context) (float64, error) {
+	settings, err := gorm.G[VolumeSettings](a.db).Where("username = ?", currentUser(c)).First(*a.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultSecondaryCredit, nil
+	}
+	return settings.SecondaryCredit, err
+}
+
+// setMuscles are the muscles of an exercise with the hard sets and tonnage
+// logged for it.
+type setMuscles struct {
+	PrimaryMuscle    Muscle
+	SecondaryMuscles []Muscle `gorm:"serializer:json"`
+	Sets             int
+	Tonnage          float64
+}
+
+// weekSets sums the sets per exercise of the week starting at start, only
+// sets with reps count as hard sets. Workouts have no owner, like the plans
+// they are shared by everyone behind the proxy, so the sets of all users are
+// counted. Only the targets and the credit are settings of the user.
+func (a *App) weekSets(start time.Time) ([]setMuscles, error) {
+	var rows []setMuscles
+	err := a.db.WithContext(*a.ctx).
+		Table("logged_sets").
+		Select("exercises.primary_muscle, exercises.secondary_muscles, "+
+			"COUNT(logged_sets.id) AS sets, COALESCE(SUM(logged_sets.reps * logged_sets.weight), 0) AS tonnage").
+		Joins("JOIN exercises ON exercises.id = logged_sets.exercise_id").
+		Where("logged_sets.created_at >= ? AND logged_sets.created_at < ? AND logged_sets.reps > 0", start, start.AddDate(0, 0, 7)).
+		Group("exercises.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// muscleVolumes credits the sets and tonnage of the exercises to their
+// muscles.
+func muscleVolumes(rows []setMuscles, credit float64) map[Muscle]*MuscleVolume {
+	volumes := map[Muscle]*MuscleVolume{}
+	add := func(muscle Muscle, share float64, row setMuscles) {
+		volume, ok := volumes[muscle]
+		if !ok {
+			volume = &MuscleVolume{Muscle: muscle}
+			volumes[muscle] = volume
+		}
+		volume.Sets += share * float64(row.Sets)
+		volume.Tonnage += share * row.Tonnage
+	}
+	for _, row := range rows {
+		add(row.PrimaryMuscle, 1, row)
+		for _, muscle := range row.SecondaryMuscles {
+			add(muscle, credit, row)
+		}
+	}
+	return volumes
+}
+
+// parseWeek returns the start of the week of the date, the current week if
+// it is empty.
+func (a *App) parseWeek(date string) (time.Time, error) {
+	if date == "" {
+		return weekStart(a.now()), nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+	if err != nil {
+		return weekStart(a.now()), errors.New("invalid week '" + date + "'")
+	}
+	return weekStart(day), nil
+}
+
+func (a *App) renderVolume(c *gin.Context, week time.Time, err error) {
+	credit, dbErr := a.secondaryCredit(c)
+	var rows []setMuscles
+	if dbErr == nil {
+		rows, dbErr = a.weekSets(week)
+	}
+	var targets []VolumeTarget
+	if dbErr == nil {
+		targets, dbErr = gorm.G[VolumeTarget](a.db).Where("username = ?", currentUser(c)).Find(*a.ctx)
+	}
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+
+	volumes := muscleVolumes(rows, credit)
+	for i := range targets {
+		if volume, ok := volumes[targets[i].Muscle]; ok {
+			volume.Target = &targets[i]
+		} else {
+			volumes[targets[i].Muscle] = &MuscleVolume{Muscle: targets[i].Muscle, Target: &targets[i]}
+		}
+	}
+	// the muscles of the catalog in its order, followed by muscles no longer
+	// in it
+	report := []MuscleVolume{}
+	for _, name := range a.catalog.muscleNames() {
+		volume := MuscleVolume{Muscle: Muscle(name)}
+		if v, ok := volumes[volume.Muscle]; ok {
+			volume = *v
+			delete(volumes, volume.Muscle)
+		}
+		volume.Child = a.catalog.parentName(name) != ""
+		report = append(report, volume)
+	}
+	for _, muscle := range slices.Sorted(maps.Keys(volumes)) {
+		report = append(report, *volumes[muscle])
+	}
+
+	data := map[string]any{
+		"Week":     week.Format(time.DateOnly),
+		"Previous": week.AddDate(0, 0, -7).Format(time.DateOnly),
+		"Next":     week.AddDate(0, 0, 7).Format(time.DateOnly),
+		"Credit":   credit,
+		"Volumes":  report,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/volume.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// formatNumber formats the number with at most two decimals.
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
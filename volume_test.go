@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	volumeSettingsCols = []string{"Username", "UpdatedAt", "SecondaryCredit"}
+	volumeTargetCols   = []string{"ID", "CreatedAt", "UpdatedAt", "Username", "Muscle", "Min", "Max"}
+	setMusclesCols     = []string{"primary_muscle", "secondary_muscles", "sets", "tonnage"}
+	reportWeek         = time.Date(2025, 10, 6, 0, 0, 0, 0, time.Local)
+)
+
+func expectVolumeSettings(rows *sqlmock.Rows) {
+	mocksql.ExpectQuery(`SELECT * FROM "volume_settings" WHERE username = $1 ORDER BY "volume_settings"."username" LIMIT $2`).
+		WithArgs("", 1).
+		WillReturnRows(rows)
+}
+
+func expectWeekSets(rows *sqlmock.Rows, args ...driver.Value) {
+	if len(args) == 0 {
+		args = []driver.Value{sqlmock.AnyArg(), sqlmock.AnyArg()}
+	}
+	mocksql.ExpectQuery(`SELECT exercises.primary_muscle, exercises.secondary_muscles, COUNT(logged_sets.id) AS sets, COALESCE(SUM(logged_sets.reps * logged_sets.weight), 0) AS tonnage FROM "logged_sets" JOIN exercises ON exercises.id = logged_sets.exercise_id WHERE logged_sets.created_at >= $1 AND logged_sets.created_at < $2 AND logged_sets.reps > 0 GROUP BY "exercises"."id"`).
+		WithArgs(args...).
+		WillReturnRows(rows)
+}
+
+// expectVolumeReport expects the queries of the report of the week of
+// 2025-10-06 with a credit of 0.25 and targets for the chest and triceps.
+func expectVolumeReport() {
+	expectVolumeSettings(sqlmock.NewRows(volumeSettingsCols).AddRow("", reportWeek, 0.25))
+	expectWeekSets(sqlmock.NewRows(setMusclesCols).
+		AddRow("Chest", `["Shoulders", "Triceps"]`, 6, 2400.0).
+		AddRow("Triceps", `[]`, 3, 450.0),
+		reportWeek, reportWeek.AddDate(0, 0, 7))
+	mocksql.ExpectQuery(`SELECT * FROM "volume_targets" WHERE username = $1`).
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows(volumeTargetCols).
+			AddRow(1, reportWeek, reportWeek, "", "Chest", 10, 20).
+			AddRow(2, reportWeek, reportWeek, "", "Triceps", 2, 4).
+			AddRow(3, reportWeek, reportWeek, "", "Calves", 6, 0))
+}
+
+func TestMuscleVolumes(t *testing.T) {
+	volumes := muscleVolumes([]setMuscles{
+		{PrimaryMuscle: "Chest", SecondaryMuscles: []Muscle{"Triceps"}, Sets: 4, Tonnage: 1000},
+		{PrimaryMuscle: "Triceps", Sets: 2, Tonnage: 100},
+	}, 0.5)
+	assert.Equal(t, &MuscleVolume{Muscle: "Chest", Sets: 4, Tonnage: 1000}, volumes["Chest"])
+	assert.Equal(t, &MuscleVolume{Muscle: "Triceps", Sets: 4, Tonnage: 600}, volumes["Triceps"])
+
+	volume := MuscleVolume{Sets: 4}
+	assert.Equal(t, "", volume.Status())
+	volume.Target = &VolumeTarget{Min: 6}
+	assert.Equal(t, "under", volume.Status())
+	volume.Target = &VolumeTarget{Min: 2, Max: 3}
+	assert.Equal(t, "over", volume.Status())
+	volume.Target = &VolumeTarget{Min: 4, Max: 4}
+	assert.Equal(t, "", volume.Status())
+}
+
+func TestVolume(t *testing.T) {
+	router, _ := SetupTestApp()
+	week := "2025-10-08"
+
+	tests := []struct {
+		method  string
+		url     string
+		form    url.Values
+		dbmocks func()
+		fixture string
+	}{
+		{
+			"GET", "/workout/volume?week=" + week, nil,
+			expectVolumeReport,
+			"./fixtures/volume/report.html",
+		},
+		{
+			"POST", "/workout/volume/credit", url.Values{"week": {week}, "credit": {"0.25"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`INSERT INTO "volume_settings" ("username","updated_at","secondary_credit") VALUES ($1,$2,$3) ON CONFLICT ("username") DO UPDATE SET "updated_at"="excluded"."updated_at","secondary_credit"="excluded"."secondary_credit"`).
+					WithArgs("", sqlmock.AnyArg(), 0.25).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectVolumeReport()
+			},
+			"./fixtures/volume/credit.html",
+		},
+		{
+			"POST", "/workout/volume/target", url.Values{"week": {week}, "muscle": {"Chest"}, "min": {"10"}, "max": {"20"}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectQuery(`INSERT INTO "volume_targets" ("created_at","updated_at","username","muscle","min","max") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("username","muscle") DO UPDATE SET "updated_at"="excluded"."updated_at","min"="excluded"."min","max"="excluded"."max" RETURNING "id"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "", "Chest", 10.0, 20.0).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mocksql.ExpectCommit()
+				expectVolumeReport()
+			},
+			"./fixtures/volume/target.html",
+		},
+		{
+			"POST", "/workout/volume/target", url.Values{"week": {week}, "muscle": {"Chest"}, "min": {"10"}, "max": {"5"}},
+			expectVolumeReport,
+			"./fixtures/volume/target_range_error.html",
+		},
+		{
+			"POST", "/workout/volume/target", url.Values{"week": {week}, "muscle": {"Calves"}, "min": {""}, "max": {""}},
+			func() {
+				mocksql.ExpectBegin()
+				mocksql.ExpectExec(`DELETE FROM "volume_targets" WHERE username = $1 AND muscle = $2`).
+					WithArgs("", "Calves").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mocksql.ExpectCommit()
+				expectVolumeReport()
+			},
+			"./fixtures/volume/target_remove.html",
+		},
+	}
+
+	for _, tt := range tests {
+		testname := filepath.Base(tt.fixture)
+		t.Run(testname, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			tt.dbmocks()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, tt.fixture, w)
+		})
+	}
+}
+
+func TestVolumeInvalidWeek(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/workout/volume?week=last", nil)
+	req.Header.Set("HX-Request", "true")
+	expectVolumeSettings(sqlmock.NewRows(volumeSettingsCols))
+	expectWeekSets(sqlmock.NewRows(setMusclesCols), weekStart(time.Now()), weekStart(time.Now()).AddDate(0, 0, 7))
+	mocksql.ExpectQuery(`SELECT * FROM "volume_targets" WHERE username = $1`).
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows(volumeTargetCols))
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, w.Body.String(), "invalid week &#39;last&#39;")
+	assert.Contains(t, w.Body.String(), "Week of "+weekStart(time.Now()).Format(time.DateOnly))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/donseba/go-htmx"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// Workout is a training session, usually executing a plan.
+type Workout struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	PlanID    *uint
+	Plan      *Plan `gorm:"constraint:OnDelete:SET NULL"`
+	// PlanVersion is the version of the plan the workout was started with,
+	// the plan can be edited since
+	PlanVersionID *uint
+	PlanVersion   *PlanVersion
+	FinishedAt    *time.Time
+	Units         []WorkoutUnit `gorm:"constraint:OnDelete:CASCADE"`
+	// ProgramID and Week are set for workouts of a program, the targets are
+	// scaled by the Intensity in percent and halved in a Deload week
+	ProgramID *uint
+	Week      int
+	Intensity float64 `gorm:"not null;default:100"`
+	Deload    bool
+}
+
+// Targets returns the targets of the plan unit scaled for the workout, nil
+// without targets or if the exercise was swapped.
+func (w Workout) Targets(unit WorkoutUnit) *UnitTargets {
+	planned := w.plannedUnit(unit)
+	if planned == nil || planned.Reps == 0 || planned.ExerciseID != unit.ExerciseID {
+		return nil
+	}
+	targets := planned.UnitTargets.scaled(ProgramWeek{Intensity: w.Intensity, Deload: w.Deload})
+	return &targets
+}
+
+// plannedSets returns the sets of the plan as the workout was started with
+// them, workouts without a version use the current plan.
+func (w Workout) plannedSets() []Set {
+	if w.PlanVersion != nil {
+		return w.PlanVersion.plan().Sets
+	}
+	if w.Plan != nil {
+		return w.Plan.Sets
+	}
+	return nil
+}
+
+// plannedUnit returns the plan unit of the workout unit as the workout was
+// started with it. Units of a version are found by position, they are kept
+// when the unit is deleted from the plan.
+func (w Workout) plannedUnit(unit WorkoutUnit) *Unit {
+	if w.PlanVersion == nil {
+		return unit.Unit
+	}
+	position := 0
+	for _, set := range w.plannedSets() {
+		for i := range set.Units {
+			if position == unit.Position {
+				return &set.Units[i]
+			}
+			position++
+		}
+	}
+	return nil
+}
+
+// WorkoutUnit is a unit of the plan as it is performed in the workout, the
+// exercise can differ from the planned one if it was swapped.
+type WorkoutUnit struct {
+	ID         uint
+	WorkoutID  uint
+	UnitID     *uint
+	Unit       *Unit `gorm:"constraint:OnDelete:SET NULL"`
+	Position   int
+	ExerciseID uint
+	Exercise   Exercise
+	Sets       []LoggedSet `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// LoggedSet is a set as performed, EstimatedMax is estimated with the
+// formula of the user when it is logged.
+type LoggedSet struct {
+	ID            uint
+	CreatedAt     time.Time
+	WorkoutUnitID uint `gorm:"uniqueIndex:idx_logged_sets_timer_round,where:timer_round > 0"`
+	ExerciseID    uint
+	Reps          int     `form:"reps" binding:"gte=0"`
+	Weight        float64 `form:"weight" binding:"gte=0"`
+	RPE           float64 `form:"rpe" binding:"gte=0,lte=10"`
+	// Drop continues the set before at a lower weight without rest
+	Drop         bool    `form:"drop"`
+	EstimatedMax float64 `form:"-"`
+	// TimerRound is the round of the timer that logged the set with the
+	// targets, 0 for sets entered. The targets are not what was performed,
+	// timer sets set no records and do not move the targets on
+	TimerRound int              `form:"-" gorm:"not null;default:0;uniqueIndex:idx_logged_sets_timer_round"`
+	Records    []PersonalRecord `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type SwapInput struct {
+	ExerciseID uint `form:"exercise" binding:"required"`
+}
+
+func (a *App) ListWorkouts(c *gin.Context) {
+	workouts, err := gorm.G[Workout](a.db).
+		Preload("Plan", nil).
+		Order("created_at DESC").
+		Find(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	plans, err := gorm.G[Plan](a.db).Order("name").Find(*a.ctx)
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+
+	data := map[string]any{
+		"Workouts": workouts,
+		"Plans":    plans,
+	}
+	page := htmx.NewComponent("templates/pages/workouts.html").
+		SetData(data).
+		AddTemplateFunction("planAction", planAction).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+// StartWorkout creates a workout from the units of the plan given as query
+// parameter, or of todays plan of the program, and continues with the
+// session view.
+func (a *App) StartWorkout(c *gin.Context) {
+	workout := Workout{Intensity: 100}
+	var planID any = c.Query("plan")
+	var err error
+	if program := c.Query("program"); program != "" {
+		var week ProgramWeek
+		planID, week, err = a.programWorkout(program, a.now())
+		workout.ProgramID = &week.ProgramID
+		workout.Week, workout.Intensity, workout.Deload = week.Week, week.Intensity, week.Deload
+	}
+	var plan Plan
+	if err == nil {
+		plan, err = a.loadPlan(planID)
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		a.ListWorkouts(c)
+		return
+	}
+
+	workout.PlanID = &plan.ID
+	for _, set := range plan.Sets {
+		for _, unit := range set.Units {
+			workout.Units = append(workout.Units, WorkoutUnit{
+				UnitID:     &unit.ID,
+				Position:   len(workout.Units),
+				ExerciseID: unit.ExerciseID,
+			})
+		}
+	}
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		version, err := a.recordPlanVersion(tx, plan, currentUser(c))
+		if err != nil {
+			return err
+		}
+		workout.PlanVersionID = &version.ID
+		return gorm.G[Workout](tx).Create(*a.ctx, &workout)
+	})
+	if err != nil {
+		log.Printf("db error: %v", err)
+		a.ListWorkouts(c)
+		return
+	}
+
+	c.Header("HX-Location", `{"path":"/workout/`+strconv.FormatUint(uint64(workout.ID), 10)+`", "target":"#content"}`)
+}
+
+func (a *App) ReadWorkout(c *gin.Context) {
+	a.renderWorkout(c, nil)
+}
+
+// renderWorkout shows the workout with the error of the action before.
+func (a *App) renderWorkout(c *gin.Context, err error) {
+	workout, dbErr := a.loadWorkout(c.Param("id"))
+	if dbErr != nil {
+		log.Printf("db error: %v", dbErr)
+		err = errors.Join(err, dbErr)
+	}
+	data := map[string]any{
+		"Workout": workout,
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	page := htmx.NewComponent("templates/pages/workout.html").
+		SetData(data).
+		AddTemplateFunction("number", formatNumber).
+		Wrap(mainContent(), "Content")
+	a.render(c, &page)
+}
+
+func (a *App) LogSet(c *gin.Context) {
+	var set LoggedSet
+	unit, err := a.openWorkoutUnit(c.Param("id"), c.Param("unit"))
+	if err == nil {
+		err = c.ShouldBindWith(&set, binding.Form)
+	}
+	if err == nil {
+		set.WorkoutUnitID = unit.ID
+		set.ExerciseID = unit.ExerciseID
+		err = a.logSet(c, &set)
+	}
+	if err != nil {
+		log.Printf("log set error: %v", err)
+	}
+	a.renderWorkout(c, err)
+}
+
+// FinishWorkout ends the workout and computes the targets of the next
+// session of its plan units, deload workouts leave them.
+func (a *App) FinishWorkout(c *gin.Context) {
+	id := c.Param("id")
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		finished, err := gorm.G[Workout](tx).
+			Where("id = ? AND finished_at IS NULL", id).
+			Update(*a.ctx, "finished_at", a.now())
+		if err != nil || finished == 0 {
+			return err
+		}
+		workout, err := gorm.G[Workout](tx).Where("id = ?", id).First(*a.ctx)
+		if err != nil || workout.Deload {
+			return err
+		}
+		return a.progressUnits(tx, workout)
+	})
+	if err != nil {
+		log.Printf("db error: %v", err)
+	}
+	a.ReadWorkout(c)
+}
+
+// SwapUnit replaces the exercise of a workout unit, the plan stays unchanged.
+func (a *App) SwapUnit(c *gin.Context) {
+	var input SwapInput
+	unit, err := a.openWorkoutUnit(c.Param("id"), c.Param("unit"))
+	if err == nil {
+		err = c.ShouldBindWith(&input, binding.Form)
+	}
+	if err == nil {
+		// like the substitutes, exercises in the trash are not offered
+		_, err = gorm.G[Exercise](a.db).
+			Select("id").
+			Where("id = ? AND trashed_at IS NULL", input.ExerciseID).
+			First(*a.ctx)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("the exercise is in the trash or was deleted")
+		}
+	}
+	if err == nil {
+		_, err = gorm.G[WorkoutUnit](a.db).
+			Where("id = ?", unit.ID).
+			Update(*a.ctx, "exercise_id", input.ExerciseID)
+	}
+	if err != nil {
+		log.Printf("swap error: %v", err)
+	}
+	a.renderWorkout(c, err)
+}
+
+// UnitSubstitutes lists the exercises a workout unit can be swapped to, the
+// equipment of the current exercise but the body weight counts as busy by
+// default.
+func (a *App) UnitSubstitutes(c *gin.Context) {
+	link := "/workout/" + c.Param("id") + "/unit/" + c.Param("unit")
+	data := map[string]any{
+		"Link":     link + "/substitutes",
+		"SwapLink": link + "/swap",
+	}
+	unit, err := a.loadWorkoutUnit(c.Param("id"), c.Param("unit"))
+	var exercise Exercise
+	if err == nil {
+		exercise, err = gorm.G[Exercise](a.db).Where("id = ?", unit.ExerciseID).First(*a.ctx)
+	}
+	if err != nil {
+		log.Printf("db error: %v", err)
+		a.renderSubstitutes(c, data, err)
+		return
+	}
+
+	free := []Equipment{}
+	for _, equipment := range catalogValues[equipmentNames](a.catalog) {
+		if equipment == BodyWeight || !slices.Contains(exercise.Equipment, equipment) {
+			free = append(free, equipment)
+		}
+	}
+	a.substitutes(c, exercise, free, data)
+}
+
+func (a *App) loadWorkout(id string) (Workout, error) {
+	return gorm.G[Workout](a.db).
+		Preload("Plan", nil).
+		Preload("Plan.Sets", nil).
+		Preload("PlanVersion", nil).
+		Preload("Units", func(db gorm.PreloadBuilder) error {
+			db.Order("position")
+			return nil
+		}).
+		Preload("Units.Exercise", nil).
+		Preload("Units.Unit", nil).
+		Preload("Units.Sets", func(db gorm.PreloadBuilder) error {
+			db.Order("id")
+			return nil
+		}).
+		Preload("Units.Sets.Records", func(db gorm.PreloadBuilder) error {
+			db.Order("id")
+			return nil
+		}).
+		Where("id = ?", id).
+		First(*a.ctx)
+}
+
+func (a *App) loadWorkoutUnit(workout string, unit string) (WorkoutUnit, error) {
+	return gorm.G[WorkoutUnit](a.db).
+		Where("id = ? AND workout_id = ?", unit, workout).
+		First(*a.ctx)
+}
+
+// openWorkoutUnit loads the unit of a workout that is not finished, like
+// FinishWorkout a finished workout is left as it is.
+func (a *App) openWorkoutUnit(workout string, unit string) (WorkoutUnit, error) {
+	workoutUnit, err := a.loadWorkoutUnit(workout, unit)
+	if err != nil {
+		return workoutUnit, err
+	}
+	finished, err := gorm.G[Workout](a.db).
+		Where("id = ? AND finished_at IS NOT NULL", workoutUnit.WorkoutID).
+		Count(*a.ctx, "id")
+	if err == nil && finished > 0 {
+		err = errors.New("the workout is finished")
+	}
+	return workoutUnit, err
+}
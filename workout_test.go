@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	workoutCols     = []string{"ID", "CreatedAt", "UpdatedAt", "PlanID", "FinishedAt", "Intensity"}
+	workoutUnitCols = []string{"ID", "WorkoutID", "UnitID", "Position", "ExerciseID"}
+)
+
+func expectUnit() {
+	mocksql.ExpectQuery(`SELECT * FROM "units" WHERE "units"."id" = $1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(unitCols).AddRow(7, 4, 0, 2, 0, 3, 5, 60.0, "Linear", 2.5, 0, 0, 0.0, 0.0, 0.0))
+}
+
+// expectPlanSets expects the sets of the plan of a workout.
+func expectPlanSets() {
+	mocksql.ExpectQuery(`SELECT * FROM "sets" WHERE "sets"."plan_id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(setCols).AddRow(4, 1, 0, "Straight", 0, 0, 0))
+}
+
+// expectOpenWorkout expects the check that the workout of a unit is not
+// finished.
+func expectOpenWorkout(finished int) {
+	mocksql.ExpectQuery(`SELECT COUNT("id") FROM "workouts" WHERE id = $1 AND finished_at IS NOT NULL`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(finished))
+}
+
+func TestStartWorkout(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/workout?plan=1", nil)
+	req.Header.Set("HX-Request", "true")
+	expectLoadPlan()
+	mocksql.ExpectBegin()
+	expectNewPlanVersion()
+	mocksql.ExpectQuery(`INSERT INTO "workouts" ("created_at","updated_at","plan_id","plan_version_id","finished_at","program_id","week","intensity","deload") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 2, nil, nil, 0, 100.0, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mocksql.ExpectQuery(`INSERT INTO "workout_units" ("workout_id","unit_id","position","exercise_id") VALUES ($1,$2,$3,$4) ON CONFLICT ("id") DO UPDATE SET "workout_id"="excluded"."workout_id" RETURNING "id"`).
+		WithArgs(5, 7, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mocksql.ExpectCommit()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"path":"/workout/5", "target":"#content"}`, w.Header().Get("HX-Location"))
+	if err := mocksql.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSwapUnit(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	form := url.Values{"exercise": {"1"}}
+	req, _ := http.NewRequest("POST", "/workout/5/unit/3/swap", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+		WithArgs("3", "5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	expectOpenWorkout(0)
+	mocksql.ExpectQuery(`SELECT "id" FROM "exercises" WHERE id = $1 AND trashed_at IS NULL ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mocksql.ExpectBegin()
+	mocksql.ExpectExec(`UPDATE "workout_units" SET "exercise_id"=$1 WHERE id = $2`).
+		WithArgs(1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mocksql.ExpectCommit()
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, 1, nil, 100.0))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	expectPlanSets()
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 1))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex1...))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1 ORDER BY id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight"}).
+			AddRow(1, t2, 3, 2, 5, 60.0))
+	mocksql.ExpectQuery(`SELECT * FROM "personal_records" WHERE "personal_records"."logged_set_id" = $1 ORDER BY id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(recordCols))
+	expectUnit()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/workout/swap.html", w)
+}
+
+func TestSwapUnitTrashed(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	w := httptest.NewRecorder()
+	form := url.Values{"exercise": {"1"}}
+	req, _ := http.NewRequest("POST", "/workout/5/unit/3/swap", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+		WithArgs("3", "5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	expectOpenWorkout(0)
+	mocksql.ExpectQuery(`SELECT "id" FROM "exercises" WHERE id = $1 AND trashed_at IS NULL ORDER BY "exercises"."id" LIMIT $2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+		WithArgs("5", 1).
+		WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, 1, nil, 100.0))
+	mocksql.ExpectQuery(`SELECT * FROM "plans" WHERE "plans"."id" = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(planCols).AddRow(plan1...))
+	expectPlanSets()
+	mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+	mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+	mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1 ORDER BY id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight"}))
+	expectUnit()
+	router.ServeHTTP(w, req)
+
+	validateFixture(t, "./fixtures/workout/swap_trashed.html", w)
+	assert.NoError(t, mocksql.ExpectationsWereMet())
+}
+
+func TestWorkoutFinished(t *testing.T) {
+	router, _ := SetupTestApp()
+
+	for _, action := range []string{"set", "swap"} {
+		t.Run(action, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			form := url.Values{"reps": {"6"}, "weight": {"60"}, "exercise": {"1"}}
+			req, _ := http.NewRequest("POST", "/workout/5/unit/3/"+action, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE id = $1 AND workout_id = $2 ORDER BY "workout_units"."id" LIMIT $3`).
+				WithArgs("3", "5", 1).
+				WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+			expectOpenWorkout(1)
+			mocksql.ExpectQuery(`SELECT * FROM "workouts" WHERE id = $1 ORDER BY "workouts"."id" LIMIT $2`).
+				WithArgs("5", 1).
+				WillReturnRows(sqlmock.NewRows(workoutCols).AddRow(5, t2, t2, nil, t2, 100.0))
+			mocksql.ExpectQuery(`SELECT * FROM "workout_units" WHERE "workout_units"."workout_id" = $1 ORDER BY position`).
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows(workoutUnitCols).AddRow(3, 5, 7, 0, 2))
+			mocksql.ExpectQuery(`SELECT * FROM "exercises" WHERE "exercises"."id" = $1`).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(exCols).AddRow(ex2...))
+			mocksql.ExpectQuery(`SELECT * FROM "logged_sets" WHERE "logged_sets"."workout_unit_id" = $1 ORDER BY id`).
+				WithArgs(3).
+				WillReturnRows(sqlmock.NewRows([]string{"ID", "CreatedAt", "WorkoutUnitID", "ExerciseID", "Reps", "Weight"}))
+			expectUnit()
+			router.ServeHTTP(w, req)
+
+			validateFixture(t, "./fixtures/workout/finished.html", w)
+			assert.NoError(t, mocksql.ExpectationsWereMet())
+		})
+	}
+}